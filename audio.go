@@ -0,0 +1,355 @@
+package fftool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glvd/go-fftool/m3u8"
+	"golang.org/x/xerrors"
+)
+
+const audioCodecs = "mp4a.40.2"
+const stillCodecs = "avc1.42e01e,"
+const variantStill = "still"
+const coverFileName = "cover.jpg"
+const metadataFileName = ".metadata.ts"
+
+// ErrAudioMetadata ...
+var ErrAudioMetadata = xerrors.New("timed metadata needs mpegts segments")
+
+// ErrAudioCodec ...
+var ErrAudioCodec = xerrors.New("audio codec is not supported by HLS")
+
+// hlsAudioCodecs the CODECS of the audio codecs and of the encoders writing them
+var hlsAudioCodecs = map[string]string{
+	"aac":        audioCodecs,
+	"libfdk_aac": audioCodecs,
+	"mp3":        "mp4a.40.34",
+	"libmp3lame": "mp4a.40.34",
+	"ac3":        "ac-3",
+	"eac3":       "ec-3",
+	"opus":       "Opus",
+	"libopus":    "Opus",
+	"flac":       "fLaC",
+}
+
+// AudioBitRatesOption encode the audio of an audio only input to AAC variants of the kbps bit rates
+func AudioBitRatesOption(kbps ...int64) SplitOptions {
+	return func(args *SplitArgs) {
+		args.audioBitRates = nil
+		for _, k := range kbps {
+			args.audioBitRates = append(args.audioBitRates, k*1000)
+		}
+	}
+}
+
+// AudioFMP4Option write the audio of an audio only input as fragmented mp4 segments
+func AudioFMP4Option(b bool) SplitOptions {
+	return func(args *SplitArgs) {
+		args.audioFMP4 = b
+	}
+}
+
+// CoverArtOption add the embedded cover art of an audio only input as a still image video variant
+func CoverArtOption(b bool) SplitOptions {
+	return func(args *SplitArgs) {
+		args.coverArt = b
+	}
+}
+
+// TimedMetadataOption add ID3 tags at the times of an audio only input, the players read them from the segments
+func TimedMetadataOption(m ...TimedMetadata) SplitOptions {
+	return func(args *SplitArgs) {
+		args.metadata = append(args.metadata, m...)
+	}
+}
+
+// audioSettings returns the settings of the audio only output
+func (sa *SplitArgs) audioSettings() string {
+	if !sa.AudioOnly {
+		return ""
+	}
+	return fmt.Sprintf(",bitrates=%v,fmp4=%t,cover=%t,metadata=%+v", sa.audioBitRates, sa.audioFMP4, sa.coverArt, sa.metadata)
+}
+
+// coverStream returns the attached picture used as the cover art, nil when there is none
+func (sa *SplitArgs) coverStream() *Stream {
+	if !sa.coverArt || sa.StreamFormat == nil {
+		return nil
+	}
+	for i := range sa.StreamFormat.Streams {
+		s := &sa.StreamFormat.Streams[i]
+		if s.CodecType == "video" && s.IsAttachedPic() {
+			return s
+		}
+	}
+	return nil
+}
+
+// audioVariant an audio playlist of the audio only output
+type audioVariant struct {
+	name     string
+	bitRate  int64
+	codecs   string
+	playlist string
+}
+
+// splitAudio write the audio only HLS of file to work, every bit rate is a variant and the
+// cover art is a still image variant using the audio variants as its rendition group
+func (sa *SplitArgs) splitAudio(ctx Context, file string, work string) error {
+	if len(sa.metadata) > 0 && sa.audioFMP4 {
+		return ErrAudioMetadata
+	}
+	cover := sa.coverStream()
+	rates := sa.audioBitRates
+	if len(rates) == 0 {
+		rates = []int64{0}
+	}
+	master := len(rates) > 1 || cover != nil
+	codecs := make([]string, len(rates))
+	for i, rate := range rates {
+		var e error
+		if codecs[i], e = sa.audioCodecs(rate); e != nil {
+			return e
+		}
+	}
+	if sa.Safe {
+		if e := checkSafeOutput(sa.Root, sa.Output, work, filepath.Join(work, sa.M3U8)); e != nil {
+			return e
+		}
+	}
+	if sa.Auto {
+		if e := os.MkdirAll(work, os.ModePerm); e != nil {
+			return e
+		}
+	}
+
+	input := file
+	if sa.Safe {
+		input = "file:" + file
+	}
+	var meta string
+	if len(sa.metadata) > 0 {
+		meta = filepath.Join(work, metadataFileName)
+		if e := writeID3TS(meta, sa.metadata); e != nil {
+			return e
+		}
+		defer os.Remove(meta)
+	}
+
+	var variants []audioVariant
+	for i, rate := range rates {
+		v := audioVariant{name: fmt.Sprintf("%s%d", groupAudio, i), bitRate: rate, codecs: codecs[i], playlist: sa.M3U8}
+		sfn := sa.SegmentFileName
+		if master {
+			v.playlist, sfn = variantName(v.name, sa.M3U8), variantName(v.name, sa.SegmentFileName)
+		}
+		if v.bitRate == 0 {
+			v.bitRate = audioBitRate(sa.AudioStream, sa.Audio)
+		}
+		ffmpeg := sa.audioCommand(input, meta, rate)
+		sa.hlsArgs(ffmpeg, work, v.name, sfn, v.playlist)
+		if e := ffmpegRun(ctx, ffmpeg); e != nil {
+			return e
+		}
+		variants = append(variants, v)
+	}
+
+	if master {
+		still := ""
+		if cover != nil {
+			var e error
+			if still, e = sa.writeStill(ctx, input, work, cover); e != nil {
+				return e
+			}
+		}
+		if e := sa.writeAudioMaster(work, variants, still); e != nil {
+			return e
+		}
+	}
+	if sa.validate != nil {
+		return sa.validateOutput(work)
+	}
+	return nil
+}
+
+// audioCommand returns the ffmpeg reading the audio of input and the metadata stream, the audio is
+// encoded to AAC of rate when rate is set
+func (sa *SplitArgs) audioCommand(input string, meta string, rate int64) *Command {
+	in, out := sa.Limits.args()
+	if sa.Safe {
+		in = strings.Join([]string{safeInputOptions, in}, " ")
+	}
+	ffmpeg := NewFFMpeg()
+	ffmpeg.SetArgs("-y " + in + " -i")
+	ffmpeg.AddArgs(input)
+	if meta != "" {
+		ffmpeg.Args = append(ffmpeg.Args, "-f", "mpegts", "-i", meta)
+	}
+	args := []string{mapArgs(sa.AudioStream)}
+	if meta != "" {
+		args = append(args, "-map 1:0 -c:d copy")
+	}
+	if rate > 0 {
+		args = append(args, fmt.Sprintf("-c:a aac -b:a %dk", rate/1000))
+	} else {
+		args = append(args, "-c:a "+sa.Audio)
+	}
+	ffmpeg.Args = append(ffmpeg.Args, strings.Fields(strings.Join(append(args, out), " "))...)
+	return ffmpeg
+}
+
+// audioCodecs returns the CODECS of an audio variant of rate, a rate is encoded to AAC-LC, an encoder
+// is mapped by its name and a copied stream by its codec and the AAC object type of its profile
+func (sa *SplitArgs) audioCodecs(rate int64) (string, error) {
+	if rate > 0 {
+		return audioCodecs, nil
+	}
+	codec := sa.Audio
+	if codec == "copy" {
+		if sa.AudioStream == nil {
+			return audioCodecs, nil
+		}
+		codec = sa.AudioStream.CodecName
+		if codec == "aac" {
+			switch sa.AudioStream.Profile {
+			case "HE-AAC":
+				return "mp4a.40.5", nil
+			case "HE-AACv2":
+				return "mp4a.40.29", nil
+			}
+		}
+	}
+	if c, ok := hlsAudioCodecs[codec]; ok {
+		return c, nil
+	}
+	return "", xerrors.Errorf("%s: %w", codec, ErrAudioCodec)
+}
+
+// hlsArgs add the hls muxer arguments writing the segments sfn and the playlist to dir
+func (sa *SplitArgs) hlsArgs(ffmpeg *Command, dir string, name string, sfn string, playlist string) {
+	ffmpeg.Args = append(ffmpeg.Args, "-f", "hls", "-hls_list_size", "0", "-hls_time", fmt.Sprint(sa.HLSTime))
+	if sa.audioFMP4 {
+		sfn = strings.TrimSuffix(sfn, filepath.Ext(sfn)) + ".m4s"
+		ffmpeg.Args = append(ffmpeg.Args, "-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", variantName(name, "init.mp4"))
+	}
+	ffmpeg.Args = append(ffmpeg.Args, "-hls_segment_filename", filepath.Join(dir, sfn), filepath.Join(dir, playlist))
+	ffmpeg.OutPath = dir
+	ffmpeg.Limits = sa.Limits
+}
+
+// writeStill extract the cover art to Cover and encode it as a still image variant lasting the
+// whole audio, returns the playlist of the variant
+func (sa *SplitArgs) writeStill(ctx Context, input string, dir string, cover *Stream) (string, error) {
+	d, e := sa.StreamFormat.Format.DurationValue()
+	if e != nil {
+		return "", xerrors.Errorf("the still image needs the duration of the audio: %w", e)
+	}
+	if d <= 0 {
+		return "", xerrors.New("the still image needs the duration of the audio")
+	}
+	in, out := sa.Limits.args()
+	if sa.Safe {
+		in = strings.Join([]string{safeInputOptions, in}, " ")
+	}
+	path := filepath.Join(dir, coverFileName)
+	ffmpeg := NewFFMpeg()
+	ffmpeg.SetArgs("-y " + in + " -i")
+	ffmpeg.AddArgs(input)
+	ffmpeg.Args = append(ffmpeg.Args, strings.Fields(fmt.Sprintf("-map 0:%d -frames:v 1 %s", cover.Index, out))...)
+	ffmpeg.AddArgs(path)
+	ffmpeg.OutPath = dir
+	ffmpeg.Limits = sa.Limits
+	if e := ffmpegRun(ctx, ffmpeg); e != nil {
+		return "", e
+	}
+	sa.Cover = coverFileName
+
+	playlist := variantName(variantStill, sa.M3U8)
+	ffmpeg = NewFFMpeg()
+	ffmpeg.SetArgs("-y -loop 1 -framerate 1 -i")
+	ffmpeg.AddArgs(path)
+	ffmpeg.Args = append(ffmpeg.Args, strings.Fields(fmt.Sprintf("-t %.3f -c:v libx264 -tune stillimage -profile:v baseline -level 3.0 -pix_fmt yuv420p "+
+		"-vf scale=trunc(iw/2)*2:trunc(ih/2)*2 -r 1 -g %d %s", d.Seconds(), sa.HLSTime, out))...)
+	sa.hlsArgs(ffmpeg, dir, variantStill, variantName(variantStill, sa.SegmentFileName), playlist)
+	if e := ffmpegRun(ctx, ffmpeg); e != nil {
+		return "", e
+	}
+	return playlist, nil
+}
+
+// writeAudioMaster write the master playlist of the audio variants, the still variant is
+// added with the audio variants as its rendition group when it is set
+func (sa *SplitArgs) writeAudioMaster(dir string, variants []audioVariant, still string) error {
+	master := &m3u8.MasterPlaylist{Version: 3}
+	if sa.audioFMP4 {
+		master.Version = 7
+	}
+	peak := int64(0)
+	for i, v := range variants {
+		if still != "" {
+			master.Media = append(master.Media, &m3u8.Media{
+				Type:       "AUDIO",
+				GroupID:    groupAudio,
+				Name:       fmt.Sprintf("%dk", v.bitRate/1000),
+				Default:    i == 0,
+				AutoSelect: true,
+				URI:        v.playlist,
+			})
+		}
+		master.Variants = append(master.Variants, &m3u8.Variant{
+			Bandwidth: v.bitRate,
+			Codecs:    v.codecs,
+			URI:       v.playlist,
+		})
+		if v.bitRate > peak {
+			peak = v.bitRate
+		}
+	}
+	if still != "" {
+		b, e := peakBandwidth(dir, still)
+		if e != nil {
+			return e
+		}
+		master.Variants = append(master.Variants, &m3u8.Variant{
+			Bandwidth:  b + peak,
+			Codecs:     stillCodecs + variants[0].codecs,
+			Resolution: stillResolution(sa.coverStream()),
+			Audio:      groupAudio,
+			URI:        still,
+		})
+	}
+	return m3u8.WriteFile(filepath.Join(dir, sa.M3U8), master)
+}
+
+// stillResolution returns the even WIDTHxHEIGHT of the still image
+func stillResolution(cover *Stream) string {
+	if cover == nil || cover.Width == nil || cover.Height == nil {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", *cover.Width/2*2, *cover.Height/2*2)
+}
+
+// peakBandwidth returns the peak segment bit rate of the media playlist in dir
+func peakBandwidth(dir string, playlist string) (int64, error) {
+	p, e := readMediaPlaylist(filepath.Join(dir, playlist))
+	if e != nil {
+		return 0, e
+	}
+	peak := int64(0)
+	for _, seg := range p.Segments {
+		info, e := os.Stat(filepath.Join(dir, seg.URI))
+		if e != nil {
+			return 0, e
+		}
+		if seg.Duration <= 0 {
+			continue
+		}
+		if b := int64(float64(info.Size()*8) / seg.Duration.Seconds()); b > peak {
+			peak = b
+		}
+	}
+	return peak, nil
+}
@@ -0,0 +1,119 @@
+package fftool
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+// fakeAudioFFmpeg record the arguments, write a playlist to the last argument and its segment
+const fakeAudioFFmpeg = fakeLog + `for out; do :; done
+printf '#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10.000000,\nsegment.ts\n#EXT-X-ENDLIST\n' > "$out"
+printf '%01250s' '' > "$(dirname "$out")/segment.ts"
+`
+
+func testAudioFormat(t *testing.T) *StreamFormat {
+	sf := StreamFormat{}
+	e := json.Unmarshal([]byte(`{"streams": [
+		{"index": 0, "codec_type": "audio", "codec_name": "mp3"},
+		{"index": 1, "codec_type": "video", "codec_name": "mjpeg", "width": 601, "height": 600, "disposition": {"attached_pic": 1}}
+	], "format": {"filename": "episode.mp3", "duration": "20.000000"}}`), &sf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return &sf
+}
+
+// TestFFMpegSplitToM3U8_AudioOnly ...
+func TestFFMpegSplitToM3U8_AudioOnly(t *testing.T) {
+	dir, logFile, cleanup := testLogDir(t, "audio")
+	defer cleanup()
+	defer fakeCommand(t, "ffmpeg", fakeAudioFFmpeg)()
+	sf := testAudioFormat(t)
+
+	out := filepath.Join(dir, "single")
+	_ = os.MkdirAll(out, os.ModePerm)
+	sa, e := FFMpegSplitToM3U8(nil, "episode.mp3", StreamFormatOption(sf), AutoOption(false), OutputOption(out))
+	if e != nil {
+		t.Fatal(e)
+	}
+	b, _ := ioutil.ReadFile(logFile)
+	if !sa.AudioOnly || strings.TrimSpace(string(b)) != "-y -i episode.mp3 -map 0:0 -c:a aac -f hls -hls_list_size 0 -hls_time 10 -hls_segment_filename "+
+		out+"/media-%05d.ts "+out+"/media.m3u8" {
+		t.Fatal(string(b))
+	}
+
+	_ = os.Remove(logFile)
+	out = filepath.Join(dir, "ladder")
+	_ = os.MkdirAll(out, os.ModePerm)
+	sa, e = FFMpegSplitToM3U8(nil, "episode.mp3", StreamFormatOption(sf), AutoOption(false), OutputOption(out),
+		AudioBitRatesOption(64, 128), CoverArtOption(true), TimedMetadataOption(TimedMetadata{Title: "Episode"}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	b, _ = ioutil.ReadFile(logFile)
+	runs := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(runs) != 4 ||
+		!strings.Contains(runs[0], "-f mpegts -i "+out+"/.metadata.ts -map 0:0 -map 1:0 -c:d copy -c:a aac -b:a 64k -f hls") ||
+		!strings.HasSuffix(runs[1], out+"/audio1_media-%05d.ts "+out+"/audio1_media.m3u8") ||
+		!strings.HasSuffix(runs[2], "-map 0:1 -frames:v 1 "+out+"/cover.jpg") ||
+		!strings.Contains(runs[3], "-loop 1 -framerate 1 -i "+out+"/cover.jpg -t 20.000 -c:v libx264 -tune stillimage") {
+		t.Fatal(string(b))
+	}
+	if _, e := os.Stat(filepath.Join(out, metadataFileName)); !os.IsNotExist(e) {
+		t.Fatal("metadata is not removed", e)
+	}
+	b, _ = ioutil.ReadFile(filepath.Join(out, "media.m3u8"))
+	for _, want := range []string{
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="64k",DEFAULT=YES,AUTOSELECT=YES,URI="audio0_media.m3u8"`,
+		"#EXT-X-STREAM-INF:BANDWIDTH=128000,CODECS=\"mp4a.40.2\"\naudio1_media.m3u8\n",
+		"#EXT-X-STREAM-INF:BANDWIDTH=129000,CODECS=\"avc1.42e01e,mp4a.40.2\",RESOLUTION=600x600,AUDIO=\"audio\"\nstill_media.m3u8\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("missing %q in\n%s", want, string(b))
+		}
+	}
+	if sa.Cover != "cover.jpg" || sa.Estimate.BitRate != 192000 {
+		t.Fatal(sa.Cover, sa.Estimate)
+	}
+
+	if _, e = FFMpegSplitToM3U8(nil, "episode.mp3", StreamFormatOption(sf), AutoOption(false), OutputOption(out),
+		AudioFMP4Option(true), TimedMetadataOption(TimedMetadata{Title: "Episode"})); e != ErrAudioMetadata {
+		t.Fatal(e)
+	}
+}
+
+// TestSplitArgs_AudioCodecs ...
+func TestSplitArgs_AudioCodecs(t *testing.T) {
+	for _, c := range []struct {
+		audio, codec, profile string
+		rate                  int64
+		want                  string
+	}{
+		{"copy", "aac", "HE-AAC", 0, "mp4a.40.5"},
+		{"copy", "aac", "HE-AACv2", 0, "mp4a.40.29"},
+		{"copy", "aac", "LC", 0, "mp4a.40.2"},
+		{"copy", "mp3", "", 0, "mp4a.40.34"},
+		{"copy", "aac", "HE-AAC", 64000, "mp4a.40.2"},
+		{"aac", "aac", "HE-AAC", 0, "mp4a.40.2"},
+		{"libmp3lame", "aac", "", 0, "mp4a.40.34"},
+		{"ac3", "aac", "", 0, "ac-3"},
+		{"libopus", "mp3", "", 0, "Opus"},
+	} {
+		sa := &SplitArgs{Audio: c.audio, AudioStream: &Stream{CodecName: c.codec, Profile: c.profile}}
+		if codecs, e := sa.audioCodecs(c.rate); e != nil || codecs != c.want {
+			t.Errorf("%+v: %s %v", c, codecs, e)
+		}
+	}
+	for _, audio := range []string{"libvorbis", "copy"} {
+		sa := &SplitArgs{Audio: audio, AudioStream: &Stream{CodecName: "vorbis"}}
+		if _, e := sa.audioCodecs(0); !xerrors.Is(e, ErrAudioCodec) {
+			t.Errorf("%s: want codec error, got %v", audio, e)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package fftool
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const burnOutput = "[burn]"
+
+// SubtitleBurn the subtitle burned into the video, an external File is used first, then the stream
+// of Language, and the Stream index when neither is set
+type SubtitleBurn struct {
+	Stream   int64 //input stream index, used when Language is empty
+	Language string
+	File     string //external srt/ass/vtt file
+}
+
+// BurnSubtitleOption burn a subtitle into the video, the video is re-encoded
+func BurnSubtitleOption(b SubtitleBurn) SplitOptions {
+	return func(args *SplitArgs) {
+		args.Burn = &b
+	}
+}
+
+// burnFilter the resolved burn-in of an input
+type burnFilter struct {
+	burn   SubtitleBurn
+	file   string  //input file
+	stream *Stream //subtitle stream, nil for an external file
+	si     int     //index among the subtitle streams
+}
+
+// resolveBurn find the subtitle stream of b in sf
+func resolveBurn(b SubtitleBurn, file string, sf *StreamFormat) (*burnFilter, error) {
+	bf := &burnFilter{burn: b, file: file}
+	if b.File != "" {
+		return bf, nil
+	}
+	if sf == nil {
+		return nil, xerrors.New("burn subtitle stream need the probe info")
+	}
+	for i := range sf.Streams {
+		s := &sf.Streams[i]
+		if s.CodecType != "subtitle" {
+			continue
+		}
+		if (b.Language == "" && s.Index == b.Stream) ||
+			(b.Language != "" && strings.EqualFold(s.Tags.Language, b.Language)) {
+			bf.stream = s
+			return bf, nil
+		}
+		bf.si++
+	}
+	return nil, xerrors.Errorf("subtitle stream not found(stream:%d,language:%s)", b.Stream, b.Language)
+}
+
+// image reports whether the subtitle is a bitmap(PGS, DVD, DVB) which need the overlay filter
+func (bf *burnFilter) image() bool {
+	return bf.stream != nil && !textSubtitleCodecs[bf.stream.CodecName]
+}
+
+// filter returns the text subtitle filter
+func (bf *burnFilter) filter() string {
+	if bf.burn.File != "" {
+		return "subtitles=filename=" + escapeFilterValue(bf.burn.File)
+	}
+	return fmt.Sprintf("subtitles=filename=%s:si=%d", escapeFilterValue(bf.file), bf.si)
+}
+
+// complexFilter returns the overlay filter graph of image subtitles with the following filters, output is burnOutput
+func (bf *burnFilter) complexFilter(video *Stream, filters string) string {
+	graph := fmt.Sprintf("[0:%d][0:%d]overlay", video.Index, bf.stream.Index)
+	if filters != "" {
+		graph += "," + filters
+	}
+	return graph + burnOutput
+}
+
+// escapeFilterValue escape a filter option value and then the filter graph
+func escapeFilterValue(s string) string {
+	option := escapeChars(s, `\':`)
+	return escapeChars(option, `\'[],;`)
+}
+
+func escapeChars(s string, chars string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// videoFilterArgs returns the filter and the video map arguments of sa
+func (sa *SplitArgs) videoFilterArgs(file string) (args []string, videoMap string, e error) {
+	var filters []string
+	var bf *burnFilter
+	if sa.Burn != nil {
+		bf, e = resolveBurn(*sa.Burn, file, sa.StreamFormat)
+		if e != nil {
+			return nil, "", e
+		}
+		if !bf.image() {
+			filters = append(filters, bf.filter())
+		}
+	}
+	if sa.Scale != 0 {
+		filters = append(filters, fmt.Sprintf(scaleFilterTemplate, sa.Scale))
+	}
+	videoMap = mapArgs(sa.VideoStream)
+	if bf != nil && bf.image() {
+		video := sa.videoStream()
+		if video == nil {
+			return nil, "", xerrors.New("burn image subtitle need the video stream")
+		}
+		return []string{"-filter_complex", bf.complexFilter(video, strings.Join(filters, ","))}, "-map " + burnOutput, nil
+	}
+	if len(filters) > 0 {
+		args = []string{"-vf", strings.Join(filters, ",")}
+	}
+	return args, videoMap, nil
+}
+
+// FFMpegBurnSubtitles burn the subtitle into the video of file and write output,
+// the video is encoded with libx264 and the audio is copied
+func FFMpegBurnSubtitles(ctx Context, file string, output string, b SubtitleBurn) error {
+	if ctx == nil {
+		ctx = FFmpegContext()
+	}
+	var sf *StreamFormat
+	if b.File == "" {
+		var e error
+		if sf, e = FFProbeStreamFormat(file); e != nil {
+			return e
+		}
+	}
+	sa := &SplitArgs{StreamFormat: sf, Burn: &b}
+	if sf != nil {
+		sa.VideoStream = sf.Video()
+		sa.AudioStream = sf.Audio()
+	}
+	filter, videoMap, e := sa.videoFilterArgs(file)
+	if e != nil {
+		return e
+	}
+	ffmpeg := NewFFMpeg()
+	ffmpeg.Args = append([]string{"-y", "-i", file}, filter...)
+	ffmpeg.Args = append(ffmpeg.Args, strings.Fields(videoMap)...)
+	if sa.AudioStream != nil {
+		ffmpeg.Args = append(ffmpeg.Args, strings.Fields(mapArgs(sa.AudioStream))...)
+	} else if sf == nil {
+		ffmpeg.Args = append(ffmpeg.Args, "-map", "0:V:0", "-map", "0:a:0?")
+	}
+	ffmpeg.Args = append(ffmpeg.Args, "-c:v", "libx264", "-c:a", "copy", output)
+	return ffmpegRun(ctx, ffmpeg)
+}
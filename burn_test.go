@@ -0,0 +1,52 @@
+package fftool
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestEscapeFilterValue ...
+func TestEscapeFilterValue(t *testing.T) {
+	if v := escapeFilterValue(`/tmp/a:b's [1].mkv`); v != `/tmp/a\\:b\\\'s \[1\].mkv` {
+		t.Fatal(v)
+	}
+}
+
+// TestSplitArgs_VideoFilterArgs ...
+func TestSplitArgs_VideoFilterArgs(t *testing.T) {
+	sf := testVideoFormat(t)
+	sa := &SplitArgs{StreamFormat: sf, VideoStream: sf.Video(), Scale: 720}
+	args, videoMap, e := sa.videoFilterArgs("video.mkv")
+	if e != nil || strings.Join(args, " ") != "-vf scale=-2:720" || videoMap != "-map 0:0" {
+		t.Fatal(args, videoMap, e)
+	}
+
+	sa.Burn = &SubtitleBurn{Language: "eng"}
+	args, videoMap, e = sa.videoFilterArgs("video.mkv")
+	if e != nil || strings.Join(args, " ") != "-vf subtitles=filename=video.mkv:si=0,scale=-2:720" || videoMap != "-map 0:0" {
+		t.Fatal(args, videoMap, e)
+	}
+
+	sa.Burn = &SubtitleBurn{Stream: 3}
+	args, videoMap, e = sa.videoFilterArgs("video.mkv")
+	if e != nil || strings.Join(args, " ") != "-filter_complex [0:0][0:3]overlay,scale=-2:720[burn]" || videoMap != "-map [burn]" {
+		t.Fatal(args, videoMap, e)
+	}
+
+	sa.Burn = &SubtitleBurn{File: "/subs/a.srt"}
+	args, _, e = sa.videoFilterArgs("video.mkv")
+	if e != nil || args[1] != "subtitles=filename=/subs/a.srt,scale=-2:720" {
+		t.Fatal(args, e)
+	}
+
+	//the language is used before the stream index
+	sa.Burn = &SubtitleBurn{Stream: 3, Language: "eng"}
+	if args, _, e = sa.videoFilterArgs("video.mkv"); e != nil || args[1] != "subtitles=filename=video.mkv:si=0,scale=-2:720" {
+		t.Fatal(args, e)
+	}
+
+	sa.Burn = &SubtitleBurn{Language: "fre"}
+	if _, _, e = sa.videoFilterArgs("video.mkv"); e == nil {
+		t.Fatal("want stream not found error")
+	}
+}
@@ -2,23 +2,34 @@ package fftool
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/godcong/go-trait"
+	"golang.org/x/xerrors"
 )
 
 var log = trait.NewZapSugar()
 
+const interruptTimeout = 10 * time.Second
+
 // Command ...
 type Command struct {
 	Path string
 	Name string
 	Args []string
-	//OutPath string
+	//OutPath output file or directory watched by Limits.MaxOutputBytes
+	OutPath string
+	Limits  *Limits
+	//Interrupt stop a canceled process with SIGINT so it finishes the output, it is killed after interruptTimeout
+	Interrupt bool
 	//Opts    map[string][]string
 }
 
@@ -42,7 +53,7 @@ func NewFFProbe() *Command {
 
 // SetArgs ...
 func (c *Command) SetArgs(s string) {
-	c.Args = strings.Split(s, " ")
+	c.Args = strings.Fields(s)
 }
 
 // AddArgs ...
@@ -86,6 +97,35 @@ func (c *Command) Run() (string, error) {
 	return string(stdout), nil
 }
 
+// RunReader run the command and pass the stdout to fn without buffering the output,
+// the process is killed when fn returns an error
+func (c *Command) RunReader(fn func(r io.Reader) error) (e error) {
+	cmd := exec.Command(c.CMD(), c.Args...)
+	cmd.Env = c.Env()
+	//显示运行的命令
+	log.With("run", "RunReader").Info(cmd.Args)
+	stdout, e := cmd.StdoutPipe()
+	if e != nil {
+		return e
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	e = cmd.Start()
+	if e != nil {
+		return e
+	}
+	if e = fn(stdout); e != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return e
+	}
+	_, _ = io.Copy(ioutil.Discard, stdout)
+	if e = cmd.Wait(); e != nil {
+		return xerrors.Errorf("%s: %w", strings.TrimSpace(stderr.String()), e)
+	}
+	return nil
+}
+
 // Env ...
 func (c *Command) Env() []string {
 	path := os.Getenv("PATH")
@@ -102,7 +142,24 @@ func (c *Command) RunContext(ctx Context, info chan<- string) (e error) {
 	if e != nil {
 		return e
 	}
-	cmd := exec.CommandContext(ctx.Context(), c.CMD(), c.Args...)
+	limits := c.Limits
+	if limits == nil {
+		limits = &Limits{}
+	}
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	if limits.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx.Context(), limits.Timeout)
+	} else {
+		runCtx, cancel = context.WithCancel(ctx.Context())
+	}
+	defer cancel()
+	watcher := &limitWatcher{limits: limits, cancel: cancel}
+	name, args := limitCommand(c.CMD(), c.Args, c.Limits)
+	cmd := exec.CommandContext(runCtx, name, args...)
+	if c.Interrupt {
+		cmd = exec.Command(name, args...)
+	}
 	cmd.Env = os.Environ()
 	//显示运行的命令
 	log.With("run", "RunContext").Info(cmd.Args)
@@ -130,6 +187,12 @@ func (c *Command) RunContext(ctx Context, info chan<- string) (e error) {
 	if e != nil {
 		return e
 	}
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+	go watcher.watchOutput(c.OutPath, watchDone)
+	if c.Interrupt {
+		go interrupt(runCtx, cmd.Process, watchDone)
+	}
 	//done := make(chan error)
 	//go func() {
 	//	done <- cmd.Wait()
@@ -145,7 +208,12 @@ func (c *Command) RunContext(ctx Context, info chan<- string) (e error) {
 		//case err := <-done:
 		//	log.Error(err)
 		//	return
-		case <-ctx.Context().Done():
+		case <-runCtx.Done():
+			//the process is killed by the context, wait until it exited
+			_ = cmd.Wait()
+			if e := watcher.check(runCtx, cmd.ProcessState); e != nil {
+				return e
+			}
 			log.Error(ctx.Context().Err())
 			return ctx.Context().Err()
 		default:
@@ -157,6 +225,7 @@ func (c *Command) RunContext(ctx Context, info chan<- string) (e error) {
 				goto END
 			}
 			if strings.TrimSpace(string(lines)) != "" {
+				watcher.checkLine(string(lines))
 				if info != nil {
 					info <- string(lines)
 				}
@@ -166,6 +235,9 @@ func (c *Command) RunContext(ctx Context, info chan<- string) (e error) {
 	//}
 END:
 	e = cmd.Wait()
+	if err := watcher.check(runCtx, cmd.ProcessState); err != nil {
+		return err
+	}
 	if e != nil {
 		return e
 	}
@@ -176,3 +248,22 @@ END:
 	//}
 	return nil
 }
+
+// interrupt send SIGINT to the process when ctx is done and kill it when it has not exited after
+// interruptTimeout, the signal is not supported on windows so the process is killed there
+func interrupt(ctx context.Context, p *os.Process, exited <-chan struct{}) {
+	select {
+	case <-exited:
+		return
+	case <-ctx.Done():
+	}
+	if e := p.Signal(os.Interrupt); e != nil {
+		_ = p.Kill()
+		return
+	}
+	select {
+	case <-exited:
+	case <-time.After(interruptTimeout):
+		_ = p.Kill()
+	}
+}
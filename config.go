@@ -18,16 +18,16 @@ var bitRateList = []int64{
 	Scale1080P: 2000 * 1024,
 }
 
-var frameRateList = []float64{
-	Scale480P:  float64(24000)/1001 - 0.005,
-	Scale720P:  float64(24000)/1001 - 0.005,
-	Scale1080P: float64(30000)/1001 - 0.005,
+var frameRateList = []Rational{
+	Scale480P:  {Num: 24000, Den: 1001},
+	Scale720P:  {Num: 24000, Den: 1001},
+	Scale1080P: {Num: 30000, Den: 1001},
 }
 
 type Config struct {
 	Scale     Scale
 	BitRate   int64
-	FrameRate float64
+	FrameRate Rational
 }
 
 func DefaultConfig() Config {
@@ -40,7 +40,7 @@ func (c *Config) init() {
 	if c.BitRate == 0 {
 		c.BitRate = bitRateList[c.Scale]
 	}
-	if c.FrameRate == 0 {
+	if c.FrameRate.IsZero() {
 		c.FrameRate = frameRateList[c.Scale]
 	}
 }
@@ -0,0 +1,289 @@
+package fftool
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+	"golang.org/x/xerrors"
+)
+
+const segmentM3u8FFmpegTemplate = `-y %s -i %s -strict -2 -c:v %s -c:a %s -bsf:v h264_mp4toannexb %s -f segment -segment_format mpegts -segment_list_type m3u8 -segment_list_size 0`
+
+// cueTolerance a segment starting this much before a cue point is still cut at it
+const cueTolerance = 100 * time.Millisecond
+
+// ErrCueRenditions ...
+var ErrCueRenditions = xerrors.New("cue points cannot be used with audio renditions")
+
+// CueStyle the playlist tags of the cue points
+type CueStyle int
+
+// CueOutIn ...
+const (
+	CueOutIn     CueStyle = iota //EXT-X-CUE-OUT, EXT-X-CUE-OUT-CONT and EXT-X-CUE-IN
+	CueDateRange                 //EXT-X-DATERANGE with SCTE35-OUT and SCTE35-IN
+)
+
+// CuePoint an ad break at Start of the content, Duration 0 is a splice point
+type CuePoint struct {
+	ID       string
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// CuePointsOption cut the segments at the start and the end of every cue and tag them in the playlist,
+// a re-encoded video gets keyframes at the cuts, a copied video is cut at the next keyframe.
+// The segment muxer is used so the input must be probed
+func CuePointsOption(style CueStyle, cues ...CuePoint) SplitOptions {
+	return func(args *SplitArgs) {
+		args.cueStyle = style
+		args.cues = cues
+	}
+}
+
+// CueDateOption set the EXT-X-PROGRAM-DATE-TIME of the first segment for CueDateRange, the default is the split time
+func CueDateOption(t time.Time) SplitOptions {
+	return func(args *SplitArgs) {
+		args.cueDate = t
+	}
+}
+
+// cueSettings ...
+func (sa *SplitArgs) cueSettings() string {
+	var s []string
+	for _, c := range sa.cues {
+		s = append(s, fmt.Sprintf(",cue=%d:%s:%s:%s", sa.cueStyle, c.ID, c.Start, c.Duration))
+	}
+	return strings.Join(s, "")
+}
+
+// cueTimes returns the cut times, the HLSTime grid restarts at every cue boundary
+// and a cut closer than a second to the next boundary is dropped
+func (sa *SplitArgs) cueTimes(duration time.Duration) []time.Duration {
+	var bounds []time.Duration
+	for _, c := range sa.cues {
+		bounds = append(bounds, c.Start, c.Start+c.Duration)
+	}
+	bounds = append(bounds, duration)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	interval := time.Duration(sa.HLSTime) * time.Second
+	var times []time.Duration
+	prev := time.Duration(0)
+	for _, b := range bounds {
+		if b <= prev || b > duration {
+			continue
+		}
+		for t := prev + interval; b-t >= time.Second; t += interval {
+			times = append(times, t)
+		}
+		if b < duration {
+			times = append(times, b)
+		}
+		prev = b
+	}
+	return times
+}
+
+// cueArgs returns the segment muxer arguments which cut at the cue points
+func (sa *SplitArgs) cueArgs(sfn string, playlist string) ([]string, error) {
+	if sa.StreamFormat == nil {
+		return nil, xerrors.New("cue points need the probed duration of the input")
+	}
+	duration, e := sa.StreamFormat.Format.DurationValue()
+	if e != nil {
+		return nil, e
+	}
+	var list []string
+	for _, t := range sa.cueTimes(duration) {
+		list = append(list, fmt.Sprintf("%.3f", t.Seconds()))
+	}
+	var args []string
+	if len(list) == 0 {
+		return append(args, "-segment_list", playlist, sfn), nil
+	}
+	if sa.Video != "copy" {
+		args = append(args, "-force_key_frames", strings.Join(list, ","))
+	}
+	return append(args, "-segment_times", strings.Join(list, ","), "-segment_list", playlist, sfn), nil
+}
+
+// cueSegment returns the first segment starting at t
+func cueSegment(starts []time.Duration, t time.Duration) int {
+	for i, s := range starts {
+		if s >= t-cueTolerance {
+			return i
+		}
+	}
+	return -1
+}
+
+// tagCues add the cue tags to the segments of p
+func (sa *SplitArgs) tagCues(p *m3u8.MediaPlaylist) {
+	starts := make([]time.Duration, len(p.Segments))
+	var t time.Duration
+	for i, s := range p.Segments {
+		starts[i] = t
+		t += s.Duration
+	}
+	date := sa.cueDate
+	if date.IsZero() {
+		date = time.Now().UTC().Truncate(time.Second)
+	}
+	if sa.cueStyle == CueDateRange && len(p.Segments) > 0 && p.Segments[0].ProgramDateTime.IsZero() {
+		p.Segments[0].ProgramDateTime = date
+	}
+	for i, c := range sa.cues {
+		out := cueSegment(starts, c.Start)
+		if out < 0 {
+			log.With("cue", c.Start).Warn("cue point after the end")
+			continue
+		}
+		in := cueSegment(starts, c.Start+c.Duration)
+		if c.Duration == 0 {
+			in = -1
+		}
+		id := c.ID
+		if id == "" {
+			id = fmt.Sprintf("cue-%d", i+1)
+		}
+		start := starts[out]
+		switch sa.cueStyle {
+		case CueDateRange:
+			startDate := p.Segments[0].ProgramDateTime.Add(start).Format("2006-01-02T15:04:05.000Z07:00")
+			seg := p.Segments[out]
+			seg.Tags = append(seg.Tags, fmt.Sprintf(`#EXT-X-DATERANGE:ID="%s",START-DATE="%s",PLANNED-DURATION=%.3f,SCTE35-OUT=%s`,
+				id, startDate, c.Duration.Seconds(), spliceInsert(uint32(i+1), start, c.Duration, true)))
+			if in >= 0 {
+				p.Segments[in].Tags = append(p.Segments[in].Tags, fmt.Sprintf(`#EXT-X-DATERANGE:ID="%s",START-DATE="%s",DURATION=%.3f,SCTE35-IN=%s`,
+					id, startDate, (starts[in]-start).Seconds(), spliceInsert(uint32(i+1), starts[in], 0, false)))
+			}
+		default:
+			end, back := len(p.Segments), in
+			if c.Duration == 0 {
+				//a splice point returns on the same boundary
+				end, back = out, out
+			} else if in >= 0 {
+				end = in
+			}
+			p.Segments[out].Tags = append(p.Segments[out].Tags, fmt.Sprintf("#EXT-X-CUE-OUT:DURATION=%.3f", c.Duration.Seconds()))
+			for j := out + 1; j < end; j++ {
+				p.Segments[j].Tags = append(p.Segments[j].Tags, fmt.Sprintf("#EXT-X-CUE-OUT-CONT:ElapsedTime=%.3f,Duration=%.3f",
+					(starts[j]-start).Seconds(), c.Duration.Seconds()))
+			}
+			if back >= 0 {
+				p.Segments[back].Tags = append(p.Segments[back].Tags, "#EXT-X-CUE-IN")
+			}
+		}
+	}
+}
+
+// writeCues tag the cue points in the playlist written by the segment muxer
+func (sa *SplitArgs) writeCues(dir string, playlist string) error {
+	path := filepath.Join(dir, playlist)
+	p, e := readMediaPlaylist(path)
+	if e != nil {
+		return e
+	}
+	p.PlaylistType = "VOD"
+	p.TargetDuration = p.MaxDuration()
+	sa.tagCues(p)
+	return m3u8.WriteFile(path, p)
+}
+
+// bitWriter write the big-endian bit fields of a SCTE-35 section
+type bitWriter struct {
+	buf  []byte
+	bits uint
+}
+
+func (w *bitWriter) write(v uint64, n uint) {
+	for i := n; i > 0; i-- {
+		if w.bits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v>>(i-1)&1 == 1 {
+			w.buf[len(w.buf)-1] |= 1 << (7 - w.bits%8)
+		}
+		w.bits++
+	}
+}
+
+// pts90k returns d in the 33 bit 90kHz clock
+func pts90k(d time.Duration) uint64 {
+	return uint64(d) * 9 / 100000 & (1<<33 - 1)
+}
+
+// spliceInsert returns the hex splice_info_section of a splice_insert command, out leaves the network
+// for duration and the return is a splice_insert with out unset
+func spliceInsert(id uint32, pts time.Duration, duration time.Duration, out bool) string {
+	cmd := &bitWriter{}
+	cmd.write(uint64(id), 32)
+	cmd.write(0, 1)    //splice_event_cancel_indicator
+	cmd.write(0x7f, 7) //reserved
+	if out {
+		cmd.write(1, 1)
+	} else {
+		cmd.write(0, 1)
+	}
+	cmd.write(1, 1) //program_splice_flag
+	if out && duration > 0 {
+		cmd.write(1, 1)
+	} else {
+		cmd.write(0, 1)
+	}
+	cmd.write(0, 1) //splice_immediate_flag
+	cmd.write(1, 1) //event_id_compliance_flag
+	cmd.write(7, 3) //reserved
+	cmd.write(1, 1) //time_specified_flag
+	cmd.write(0x3f, 6)
+	cmd.write(pts90k(pts), 33)
+	if out && duration > 0 {
+		cmd.write(1, 1) //auto_return
+		cmd.write(0x3f, 6)
+		cmd.write(pts90k(duration), 33)
+	}
+	cmd.write(uint64(id)&0xffff, 16) //unique_program_id
+	cmd.write(0, 8)                  //avail_num
+	cmd.write(0, 8)                  //avails_expected
+
+	s := &bitWriter{}
+	s.write(0xfc, 8) //table_id
+	s.write(0, 1)    //section_syntax_indicator
+	s.write(0, 1)    //private_indicator
+	s.write(3, 2)    //sap_type
+	s.write(uint64(17+len(cmd.buf)), 12)
+	s.write(0, 8)  //protocol_version
+	s.write(0, 1)  //encrypted_packet
+	s.write(0, 6)  //encryption_algorithm
+	s.write(0, 33) //pts_adjustment
+	s.write(0, 8)  //cw_index
+	s.write(0xfff, 12)
+	s.write(uint64(len(cmd.buf)), 12)
+	s.write(5, 8) //splice_insert
+	s.buf = append(s.buf, cmd.buf...)
+	s.bits += uint(len(cmd.buf)) * 8
+	s.write(0, 16) //descriptor_loop_length
+	s.write(uint64(crc32MPEG2(s.buf)), 32)
+	return "0x" + strings.ToUpper(hex.EncodeToString(s.buf))
+}
+
+// crc32MPEG2 the CRC-32/MPEG-2 of the sections
+func crc32MPEG2(b []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, v := range b {
+		crc ^= uint32(v) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
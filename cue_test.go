@@ -0,0 +1,159 @@
+package fftool
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+)
+
+// fakeCueFFmpeg record the arguments and write the segment list of the segment muxer
+const fakeCueFFmpeg = fakeLog + `while [ $# -gt 1 ]; do
+	[ "$1" = "-segment_list" ] && list="$2"
+	shift
+done
+printf '#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-ALLOW-CACHE:YES\n#EXT-X-TARGETDURATION:11\n' > "$list"
+i=0
+for d in 10 5 10 10 10 10 5; do
+	printf '#EXTINF:%s.000000,\nmedia-%05d.ts\n' $d $i >> "$list"
+	i=$((i+1))
+done
+echo '#EXT-X-ENDLIST' >> "$list"
+`
+
+// TestSplitArgs_CueTimes ...
+func TestSplitArgs_CueTimes(t *testing.T) {
+	sa := &SplitArgs{HLSTime: 10, cues: []CuePoint{{Start: 15 * time.Second, Duration: 30 * time.Second}, {Start: 59500 * time.Millisecond}}}
+	var got []string
+	for _, d := range sa.cueTimes(60 * time.Second) {
+		got = append(got, d.String())
+	}
+	if s := strings.Join(got, ","); s != "10s,15s,25s,35s,45s,55s,59.5s" {
+		t.Fatal(s)
+	}
+}
+
+// TestFFMpegSplitToM3U8_Cues ...
+func TestFFMpegSplitToM3U8_Cues(t *testing.T) {
+	dir, logFile, cleanup := testLogDir(t, "cue")
+	defer cleanup()
+	defer fakeCommand(t, "ffmpeg", fakeCueFFmpeg)()
+	sf := testVideoFormat(t)
+	sf.Format.Duration = "60.000000"
+	cues := []CuePoint{{Start: 15 * time.Second, Duration: 30 * time.Second}}
+
+	out := filepath.Join(dir, "out")
+	_ = os.MkdirAll(out, os.ModePerm)
+	_, e := FFMpegSplitToM3U8(nil, "video.mkv", StreamFormatOption(sf), AutoOption(false), OutputOption(out), HLSTimeOption(10),
+		ScaleOption(720), CuePointsOption(CueOutIn, cues...))
+	if e != nil {
+		t.Fatal(e)
+	}
+	b, _ := ioutil.ReadFile(logFile)
+	if !strings.Contains(string(b), "-f segment -segment_format mpegts -segment_list_type m3u8 -segment_list_size 0 ") ||
+		!strings.Contains(string(b), "-force_key_frames 10.000,15.000,25.000,35.000,45.000,55.000 -segment_times 10.000,15.000,25.000,35.000,45.000,55.000 "+
+			"-segment_list "+out+"/media.m3u8 "+out+"/media-%05d.ts") {
+		t.Fatal(string(b))
+	}
+	b, _ = ioutil.ReadFile(filepath.Join(out, "media.m3u8"))
+	for _, want := range []string{
+		"#EXT-X-TARGETDURATION:10\n",
+		"#EXT-X-PLAYLIST-TYPE:VOD\n",
+		"media-00000.ts\n#EXTINF:5.000000,\nmedia-00001.ts\n#EXT-X-CUE-OUT:DURATION=30.000\n#EXTINF:10.000000,\nmedia-00002.ts\n",
+		"#EXT-X-CUE-OUT-CONT:ElapsedTime=20.000,Duration=30.000\n#EXTINF:10.000000,\nmedia-00004.ts\n#EXT-X-CUE-IN\n#EXTINF:10.000000,\nmedia-00005.ts\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("missing %q in\n%s", want, string(b))
+		}
+	}
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	out = filepath.Join(dir, "daterange")
+	_ = os.MkdirAll(out, os.ModePerm)
+	_, e = FFMpegSplitToM3U8(nil, "video.mkv", StreamFormatOption(sf), AutoOption(false), OutputOption(out), HLSTimeOption(10),
+		CuePointsOption(CueDateRange, CuePoint{ID: "break", Start: 15 * time.Second, Duration: 30 * time.Second}), CueDateOption(date))
+	if e != nil {
+		t.Fatal(e)
+	}
+	b, _ = ioutil.ReadFile(filepath.Join(out, "media.m3u8"))
+	for _, want := range []string{
+		"#EXT-X-PROGRAM-DATE-TIME:2020-01-02T03:04:05.000Z\n#EXTINF:10.000000,\nmedia-00000.ts\n",
+		`#EXT-X-DATERANGE:ID="break",START-DATE="2020-01-02T03:04:20.000Z",PLANNED-DURATION=30.000,SCTE35-OUT=0xFC`,
+		`#EXT-X-DATERANGE:ID="break",START-DATE="2020-01-02T03:04:20.000Z",DURATION=30.000,SCTE35-IN=0xFC`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("missing %q in\n%s", want, string(b))
+		}
+	}
+	b, _ = ioutil.ReadFile(logFile)
+	if runs := strings.Split(strings.TrimSpace(string(b)), "\n"); strings.Contains(runs[1], "-force_key_frames") {
+		t.Fatal("keyframes forced for a copied video", runs[1])
+	}
+}
+
+// TestFFMpegSplitToM3U8_CuesNoFormat ...
+func TestFFMpegSplitToM3U8_CuesNoFormat(t *testing.T) {
+	dir, e := ioutil.TempDir("", "cue")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	_, e = FFMpegSplitToM3U8(nil, "video.mkv", OutputOption(out), CuePointsOption(CueOutIn, CuePoint{Start: 15 * time.Second}))
+	if e == nil {
+		t.Fatal("want error")
+	}
+	var left []string
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, e error) error {
+		if path != dir && path != out {
+			left = append(left, path)
+		}
+		return nil
+	})
+	if len(left) != 0 {
+		t.Fatal("partial output left", left)
+	}
+}
+
+// TestSplitArgs_TagCues_SplicePoint ...
+func TestSplitArgs_TagCues_SplicePoint(t *testing.T) {
+	p := &m3u8.MediaPlaylist{}
+	for i := 0; i < 3; i++ {
+		p.Segments = append(p.Segments, &m3u8.Segment{URI: fmt.Sprintf("media-%05d.ts", i), Duration: 2 * time.Second})
+	}
+	sa := &SplitArgs{cueStyle: CueOutIn, cues: []CuePoint{{Start: 2 * time.Second}}}
+	sa.tagCues(p)
+	if tags := strings.Join(p.Segments[1].Tags, "\n"); tags != "#EXT-X-CUE-OUT:DURATION=0.000\n#EXT-X-CUE-IN" {
+		t.Fatal(tags)
+	}
+	if len(p.Segments[0].Tags) != 0 || len(p.Segments[2].Tags) != 0 {
+		t.Fatal(p.Segments[0].Tags, p.Segments[2].Tags)
+	}
+}
+
+// TestSpliceInsert ...
+func TestSpliceInsert(t *testing.T) {
+	if crc := crc32MPEG2([]byte("123456789")); crc != 0x0376e6e7 {
+		t.Fatalf("%x", crc)
+	}
+	for _, out := range []bool{true, false} {
+		s := spliceInsert(7, 15*time.Second, 30*time.Second, out)
+		b, e := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		if e != nil {
+			t.Fatal(e)
+		}
+		if b[0] != 0xfc || int(b[1]&0x0f)<<8|int(b[2]) != len(b)-3 || b[13] != 5 || crc32MPEG2(b) != 0 {
+			t.Fatal(s)
+		}
+		//splice_event_id and out_of_network_indicator
+		if b[14] != 0 || b[17] != 7 || b[19]&0x80 != 0 == !out {
+			t.Fatal(s)
+		}
+	}
+}
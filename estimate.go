@@ -0,0 +1,234 @@
+package fftool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const defaultAudioBitRate = 128 * 1000
+
+// tsOverhead mpegts container overhead
+const tsOverhead = 1.05
+
+// spaceMargin extra free space needed over the estimate
+const spaceMargin = 1.1
+
+// maxSpeedHistory ...
+const maxSpeedHistory = 20
+
+var defaultSpeedFactors = map[bool]float64{
+	true:  50, //copy
+	false: 1,  //encode
+}
+
+// PreflightMode ...
+type PreflightMode int
+
+// PreflightNone ...
+const (
+	PreflightNone PreflightMode = iota
+	PreflightWarn
+	PreflightRefuse
+)
+
+// Estimate ...
+type Estimate struct {
+	Duration  time.Duration
+	BitRate   int64 //bits per second
+	Bytes     int64
+	FreeBytes uint64
+	FreeKnown bool //FreeBytes was read from the file system
+	WallTime  time.Duration
+}
+
+// InsufficientSpaceError ...
+type InsufficientSpaceError struct {
+	Path string
+	Need int64
+	Free uint64
+}
+
+// Error ...
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient space on %s: need %d bytes, free %d bytes", e.Path, e.Need, e.Free)
+}
+
+// PreflightOption estimate the output and check the free space before split
+func PreflightOption(m PreflightMode) SplitOptions {
+	return func(args *SplitArgs) {
+		args.Preflight = m
+	}
+}
+
+// SpeedHistory records the speed factor(media duration/wall time) of finished splits
+type SpeedHistory struct {
+	mu      sync.Mutex
+	factors map[string][]float64
+}
+
+// DefaultSpeedHistory ...
+var DefaultSpeedHistory = NewSpeedHistory()
+
+// NewSpeedHistory ...
+func NewSpeedHistory() *SpeedHistory {
+	return &SpeedHistory{
+		factors: make(map[string][]float64),
+	}
+}
+
+// Record ...
+func (h *SpeedHistory) Record(key string, factor float64) {
+	if factor <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := append(h.factors[key], factor)
+	if len(f) > maxSpeedHistory {
+		f = f[len(f)-maxSpeedHistory:]
+	}
+	h.factors[key] = f
+}
+
+// Factor returns the average speed factor of key
+func (h *SpeedHistory) Factor(key string) (float64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.factors[key]
+	if len(f) == 0 {
+		return 0, false
+	}
+	sum := 0.0
+	for _, v := range f {
+		sum += v
+	}
+	return sum / float64(len(f)), true
+}
+
+// speedKey ...
+func (sa *SplitArgs) speedKey() string {
+	if sa.Video == "copy" {
+		return "copy"
+	}
+	return fmt.Sprintf("%s-%d", sa.Video, sa.Scale)
+}
+
+// estimate ...
+func (sa *SplitArgs) estimate() *Estimate {
+	if sa.StreamFormat == nil {
+		return nil
+	}
+	est := &Estimate{}
+	if d, e := sa.StreamFormat.Format.DurationValue(); e == nil {
+		est.Duration = d
+	}
+
+	est.BitRate = sa.videoBitRate()
+	if audios := sa.renditions("AUDIO"); len(audios) > 0 {
+		for _, r := range audios {
+			est.BitRate += audioBitRate(r.Stream, r.Codec)
+		}
+	} else if len(sa.audioBitRates) > 0 && sa.AudioOnly {
+		for _, b := range sa.audioBitRates {
+			est.BitRate += b
+		}
+	} else {
+		est.BitRate += audioBitRate(sa.audioStream(), sa.Audio)
+	}
+	est.Bytes = int64(float64(est.BitRate) / 8 * est.Duration.Seconds() * tsOverhead)
+
+	factor, ok := DefaultSpeedHistory.Factor(sa.speedKey())
+	if !ok {
+		factor = defaultSpeedFactors[sa.Video == "copy"]
+	}
+	est.WallTime = time.Duration(float64(est.Duration) / factor)
+
+	if free, e := freeSpace(existingDir(sa.Output)); e == nil {
+		est.FreeBytes, est.FreeKnown = free, true
+	} else {
+		log.With("output", sa.Output).Warn(e)
+	}
+	return est
+}
+
+func (sa *SplitArgs) audioStream() *Stream {
+	if sa.AudioStream == nil && sa.StreamFormat != nil {
+		return sa.StreamFormat.Audio()
+	}
+	return sa.AudioStream
+}
+
+func (sa *SplitArgs) videoStream() *Stream {
+	if sa.VideoStream == nil && sa.StreamFormat != nil {
+		return sa.StreamFormat.Video()
+	}
+	return sa.VideoStream
+}
+
+// videoBitRate returns the estimated output video bit rate
+func (sa *SplitArgs) videoBitRate() int64 {
+	if sa.AudioOnly {
+		return 0
+	}
+	if sa.Video != "copy" && sa.BitRate > 0 {
+		return sa.BitRate
+	}
+	if video := sa.videoStream(); video != nil {
+		if b, e := video.BitRateValue(); e == nil && b > 0 {
+			return b
+		}
+	}
+	if sa.StreamFormat == nil {
+		return 0
+	}
+	source, _ := sa.StreamFormat.Format.BitRateValue()
+	if rate := source - audioBitRate(sa.audioStream(), "copy"); rate > 0 {
+		return rate
+	}
+	return 0
+}
+
+// audioBitRate returns the estimated output bit rate of the audio stream
+func audioBitRate(s *Stream, codec string) int64 {
+	if s != nil && codec == "copy" {
+		if b, e := s.BitRateValue(); e == nil && b > 0 {
+			return b
+		}
+	}
+	return defaultAudioBitRate
+}
+
+// checkSpace compare the estimated output with the free space, an unknown free space passes
+func (sa *SplitArgs) checkSpace() error {
+	est := sa.Estimate
+	if sa.Preflight == PreflightNone || est == nil || !est.FreeKnown {
+		return nil
+	}
+	need := int64(float64(est.Bytes) * spaceMargin)
+	if uint64(need) <= est.FreeBytes {
+		return nil
+	}
+	e := &InsufficientSpaceError{Path: sa.Output, Need: need, Free: est.FreeBytes}
+	if sa.Preflight == PreflightRefuse {
+		return e
+	}
+	log.Warn(e)
+	return nil
+}
+
+// existingDir returns the nearest existing directory of path
+func existingDir(path string) string {
+	for {
+		if info, e := os.Stat(path); e == nil && info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
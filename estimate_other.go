@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package fftool
+
+import "golang.org/x/xerrors"
+
+// freeSpace ...
+func freeSpace(path string) (uint64, error) {
+	return 0, xerrors.New("free space is not supported on this platform")
+}
@@ -0,0 +1,70 @@
+package fftool
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+func testStreamFormat() *StreamFormat {
+	height := int64(1080)
+	return &StreamFormat{
+		Streams: []Stream{
+			{Index: 0, CodecType: "video", CodecName: "h264", Height: &height, BitRate: "4000000", RFrameRate: "24000/1001", AvgFrameRate: "24000/1001", TimeBase: "1/24000"},
+			{Index: 1, CodecType: "audio", CodecName: "aac", BitRate: "192000"},
+		},
+		Format: Format{Filename: "video.mp4", Duration: "100.000000", BitRate: "4192000", Size: "52400000"},
+	}
+}
+
+// TestSplitArgs_Estimate ...
+func TestSplitArgs_Estimate(t *testing.T) {
+	sa := &SplitArgs{StreamFormat: testStreamFormat(), Video: "copy", Audio: "copy", Output: os.TempDir()}
+	est := sa.estimate()
+	if est.Duration != 100*time.Second || est.BitRate != 4192000 {
+		t.Fatalf("%+v", est)
+	}
+	if est.Bytes != int64(4192000/8*100*tsOverhead) {
+		t.Fatal(est.Bytes)
+	}
+
+	sa = &SplitArgs{StreamFormat: testStreamFormat(), Video: "libx264", Audio: "copy", BitRate: 1000000, Output: os.TempDir()}
+	if est = sa.estimate(); est.BitRate != 1192000 {
+		t.Fatal(est.BitRate)
+	}
+
+	h := NewSpeedHistory()
+	h.Record("copy", 10)
+	h.Record("copy", 30)
+	if f, _ := h.Factor("copy"); f != 20 {
+		t.Fatal(f)
+	}
+}
+
+// TestSplitArgs_CheckSpace ...
+func TestSplitArgs_CheckSpace(t *testing.T) {
+	sa := &SplitArgs{Preflight: PreflightRefuse, Estimate: &Estimate{Bytes: 1000, FreeBytes: 100, FreeKnown: true}}
+	var se *InsufficientSpaceError
+	if e := sa.checkSpace(); !xerrors.As(e, &se) {
+		t.Fatal(e)
+	}
+	sa.Preflight = PreflightWarn
+	if e := sa.checkSpace(); e != nil {
+		t.Fatal(e)
+	}
+	sa = &SplitArgs{Preflight: PreflightRefuse, Estimate: &Estimate{Bytes: 1000, FreeBytes: 1 << 20, FreeKnown: true}}
+	if e := sa.checkSpace(); e != nil {
+		t.Fatal(e)
+	}
+	//a full disk
+	sa = &SplitArgs{Preflight: PreflightRefuse, Estimate: &Estimate{Bytes: 1000, FreeKnown: true}}
+	if e := sa.checkSpace(); !xerrors.As(e, &se) || se.Free != 0 {
+		t.Fatal(e)
+	}
+	sa.Estimate.FreeKnown = false
+	if e := sa.checkSpace(); e != nil {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package fftool
+
+import "syscall"
+
+// freeSpace returns the bytes available to unprivileged users
+func freeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if e := syscall.Statfs(path, &st); e != nil {
+		return 0, e
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -6,20 +6,21 @@ import (
 	"math"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/glvd/go-fftool/m3u8"
 	"golang.org/x/xerrors"
 )
 
 //const sliceM3u8FFmpegTemplate = `-y -i %s -strict -2 -ss %s -to %s -c:v %s -c:a %s -bsf:v h264_mp4toannexb -vsync 0 -f hls -hls_list_size 0 -hls_time %d -hls_segment_filename %s %s`
-const sliceM3u8FFmpegTemplate = `-y -i %s -strict -2 -c:v %s -c:a %s -bsf:v h264_mp4toannexb -f hls -hls_list_size 0 -hls_time %d -hls_segment_filename %s %s`
-const sliceM3u8ScaleTemplate = `-y -i %s -strict -2 -c:v %s -c:a %s -bsf:v h264_mp4toannexb %s -f hls -hls_list_size 0 -hls_time %d -hls_segment_filename %s %s`
-const scaleOutputTemplate = "-vf scale=-2:%d"
+//const sliceM3u8FFmpegTemplate = `-y -i %s -strict -2 -c:v %s -c:a %s -bsf:v h264_mp4toannexb -f hls -hls_list_size 0 -hls_time %d -hls_segment_filename %s %s`
+//const sliceM3u8ScaleTemplate = `-y -i %s -strict -2 -c:v %s -c:a %s -bsf:v h264_mp4toannexb %s -f hls -hls_list_size 0 -hls_time %d -hls_segment_filename %s %s`
+const sliceM3u8FFmpegTemplate = `-y %s -i %s -strict -2 -c:v %s -c:a %s -bsf:v h264_mp4toannexb %s -f hls -hls_list_size 0 -hls_time %d`
+const scaleFilterTemplate = "scale=-2:%d"
 const bitRateOutputTemplate = "-b:v %dK"
-const frameRateOutputTemplate = "-r %3.2f"
+const frameRateOutputTemplate = "-r %s"
 
 // SplitArgs ...
 type SplitArgs struct {
@@ -32,11 +33,48 @@ type SplitArgs struct {
 	Video           string
 	Audio           string
 	M3U8            string
+	Playlist        m3u8.Playlist //the parsed M3U8 after the split
 	SegmentFileName string
 	HLSTime         int
 	probe           func(string) (*StreamFormat, error)
+	customProbe     bool //probe is set by ProbeInfoOption
+	trustProbe      bool
 	BitRate         int64
-	FrameRate       float64
+	FrameRate       Rational
+	Limits          *Limits
+	Safe            bool
+	Root            string
+	Debug           bool
+	Reused          bool
+	Preflight       PreflightMode
+	Estimate        *Estimate
+	Selector        *StreamSelector
+	VideoStream     *Stream
+	AudioStream     *Stream
+	Renditions      []Rendition
+	Burn            *SubtitleBurn
+	Thumbnails      []string
+	Sprites         []string
+	Validation      *ValidationReport
+	naming          OutputNaming
+	multiAudio      bool
+	subtitles       bool
+	audioLanguages  []string
+	thumbnails      []ThumbnailArgs
+	sprites         []SpriteArgs
+	iframes         bool
+	iframeBandwidth int64
+	validate        *ValidateArgs
+	cues            []CuePoint
+	cueStyle        CueStyle
+	cueDate         time.Time
+	gopAlign        bool
+	AudioOnly       bool
+	Cover           string
+	audioBitRates   []int64
+	audioFMP4       bool
+	coverArt        bool
+	metadata        []TimedMetadata
 }
 
 // FFmpegContext ...
@@ -117,6 +155,19 @@ func FFmpegContext() Context {
 	}
 }
 
+// childContext returns a context which is canceled with parent, canceling it keeps parent running
+func childContext(parent Context) Context {
+	if parent == nil {
+		return FFmpegContext()
+	}
+	ctx, cancel := context.WithCancel(parent.Context())
+	return &ffmpegContext{
+		wg:     &sync.WaitGroup{},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
 // SplitOptions ...
 type SplitOptions func(args *SplitArgs)
 
@@ -180,16 +231,74 @@ func BitRateOption(b int64) SplitOptions {
 	}
 }
 
-// ProbeInfoOption ...
+// FrameRateOption ...
+func FrameRateOption(r Rational) SplitOptions {
+	return func(args *SplitArgs) {
+		args.FrameRate = r
+	}
+}
+
+// SelectorOption ...
+func SelectorOption(s StreamSelector) SplitOptions {
+	return func(args *SplitArgs) {
+		args.Selector = &s
+	}
+}
+
+// LanguageOption prefer the audio of languages
+func LanguageOption(lang ...string) SplitOptions {
+	return func(args *SplitArgs) {
+		if args.Selector == nil {
+			sel := DefaultStreamSelector()
+			args.Selector = &sel
+		}
+		args.Selector.Languages = lang
+	}
+}
+
+// DebugOption keep the temporary output directory when the split failed
+func DebugOption(b bool) SplitOptions {
+	return func(args *SplitArgs) {
+		args.Debug = b
+	}
+}
+
+// NamingOption set the directory naming of the auto output
+func NamingOption(n OutputNaming) SplitOptions {
+	return func(args *SplitArgs) {
+		args.naming = n
+	}
+}
+
+// LimitsOption ...
+func LimitsOption(l Limits) SplitOptions {
+	return func(args *SplitArgs) {
+		args.Limits = &l
+	}
+}
+
+// ProbeInfoOption probe the input with f, safe mode refuses it unless TrustProbeOption is set
 func ProbeInfoOption(f func(string) (*StreamFormat, error)) SplitOptions {
 	return func(args *SplitArgs) {
 		args.probe = f
+		args.customProbe = true
+	}
+}
+
+// probeOption use the default probe, only the file protocol is allowed with safe mode
+func probeOption() SplitOptions {
+	return func(args *SplitArgs) {
+		args.customProbe = false
+		args.probe = FFProbeStreamFormat
+		if args.Safe {
+			args.probe = FFProbeStreamFormatSafe
+		}
 	}
 }
 
 // FFMpegSplitToM3U8WithProbe ...
 func FFMpegSplitToM3U8WithProbe(ctx Context, file string, args ...SplitOptions) (sa *SplitArgs, e error) {
-	args = append(args, ProbeInfoOption(FFProbeStreamFormat))
+	args = append(args, probeOption())
 	return FFMpegSplitToM3U8(ctx, file, args...)
 }
 
@@ -203,13 +312,13 @@ func scaleIndex(scale int64) int {
 }
 
 func outputScale(sa *SplitArgs) string {
-	outputs := []string{fmt.Sprintf(scaleOutputTemplate, sa.Scale)}
+	var outputs []string
 
 	if sa.BitRate != 0 {
 		outputs = append(outputs, fmt.Sprintf(bitRateOutputTemplate, sa.BitRate/1024))
 	}
 	log.Info(sa.FrameRate)
-	if sa.FrameRate > 0 {
+	if !sa.FrameRate.IsZero() {
 		outputs = append(outputs, fmt.Sprintf(frameRateOutputTemplate, sa.FrameRate))
 	}
 	log.Info("output:", strings.Join(outputs, " "))
@@ -226,7 +335,7 @@ func optimizeScale(sa *SplitArgs, video *Stream) {
 		}
 
 		idx := scaleIndex(sa.Scale)
-		i, e := strconv.ParseInt(video.BitRate, 10, 64)
+		i, e := video.BitRateValue()
 		if e != nil {
 			log.Error(e)
 			i = math.MaxInt64
@@ -240,48 +349,197 @@ func optimizeScale(sa *SplitArgs, video *Stream) {
 				sa.BitRate = 0
 			}
 		}
-		log.Info(video.RFrameRate)
-		fr := strings.Split(video.RFrameRate, "/")
-		il := 1
-		ir := 1
-		if len(fr) == 2 {
-			il, e = strconv.Atoi(fr[0])
-			if e != nil {
-				il = 1
-				log.Error(e)
-			}
-			ir, e = strconv.Atoi(fr[1])
-			if e != nil {
-				ir = 1
-				log.Error(e)
-			}
-		}
-		if sa.FrameRate == 0 {
+		if sa.FrameRate.IsZero() {
 			sa.FrameRate = frameRateList[idx]
 		}
-		log.Info(sa.FrameRate, il, ir, il/ir)
-		if sa.FrameRate > 0 {
-			if sa.FrameRate > float64(il)/float64(ir) {
-				sa.FrameRate = 0
-			}
+		fr, e := video.FrameRate()
+		if e != nil {
+			log.Error(e)
+			sa.FrameRate = Rational{}
+			return
+		}
+		log.Info(sa.FrameRate, fr)
+		if sa.FrameRate.Cmp(fr) > 0 {
+			sa.FrameRate = Rational{}
 		}
 	}
 }
 
 // FFMpegSplitToM3U8WithOptimize ...
 func FFMpegSplitToM3U8WithOptimize(ctx Context, file string, args ...SplitOptions) (sa *SplitArgs, e error) {
-	args = append(args, ProbeInfoOption(FFProbeStreamFormat))
+	args = append(args, probeOption())
 	return FFMpegSplitToM3U8(ctx, file, args...)
 }
 
 // FFMpegSplitToM3U8 ...
 func FFMpegSplitToM3U8(ctx Context, file string, args ...SplitOptions) (sa *SplitArgs, e error) {
-	if strings.Index(file, " ") != -1 {
-		return nil, xerrors.New("file name cannot have spaces")
-	}
 	if ctx == nil {
 		ctx = FFmpegContext()
 	}
+	sa, e = prepareSplit(file, args...)
+	if e != nil {
+		return nil, e
+	}
+	if sa.Reused {
+		log.With("output", sa.Output).Info("reuse output")
+		if sa.Playlist, e = m3u8.ReadFile(filepath.Join(sa.Output, sa.M3U8)); e != nil {
+			return nil, e
+		}
+		return sa, nil
+	}
+	if e = sa.checkSpace(); e != nil {
+		return nil, e
+	}
+
+	//auto output is written to a temporary sibling and renamed into place on success
+	work := sa.Output
+	if sa.Auto {
+		work = tempOutput(sa.Output)
+	}
+	if sa.AudioOnly {
+		return sa.finishSplit(work, sa.splitAudio(ctx, file, work))
+	}
+
+	sfn := filepath.Join(work, sa.SegmentFileName)
+	playlist := filepath.Join(work, sa.M3U8)
+	//with renditions or I-frames sa.M3U8 is the master playlist which is written after ffmpeg
+	master := sa.master()
+	multi := len(sa.renditions("AUDIO")) > 0
+	if multi && len(sa.cues) > 0 {
+		return nil, ErrCueRenditions
+	}
+	if multi {
+		//the variants are named by -var_stream_map
+		sfn = filepath.Join(work, variantName("%v", sa.SegmentFileName))
+		playlist = filepath.Join(work, variantName("%v", sa.M3U8))
+	} else if master {
+		sfn = filepath.Join(work, variantName(variantVideo, sa.SegmentFileName))
+		playlist = filepath.Join(work, sa.videoPlaylist())
+	}
+
+	filter, videoMap, e := sa.videoFilterArgs(file)
+	if e != nil {
+		return nil, e
+	}
+	//the arguments are checked before the work directory is created
+	var cueArgs []string
+	if len(sa.cues) > 0 {
+		if cueArgs, e = sa.cueArgs(sfn, playlist); e != nil {
+			return nil, e
+		}
+	}
+	if sa.Safe {
+		if e = checkSafeOutput(sa.Root, sa.Output, work, sfn, playlist); e != nil {
+			return nil, e
+		}
+	}
+	if sa.Auto {
+		_ = os.MkdirAll(work, os.ModePerm)
+	}
+
+	input, output := sa.Limits.args()
+	if multi {
+		output = strings.Join([]string{videoMap, sa.audioArgs(), output}, " ")
+	} else {
+		output = strings.Join([]string{videoMap, mapArgs(sa.AudioStream), output}, " ")
+	}
+	if sa.Safe {
+		input = strings.Join([]string{safeInputOptions, input}, " ")
+	}
+	if sa.Scale != 0 {
+		output = strings.Join([]string{outputScale(sa), output}, " ")
+	}
+	if sa.gopAlign && sa.Video != "copy" {
+		//the cue points force their own keyframes
+		output = strings.Join([]string{sa.gopArgs(len(sa.cues) == 0), output}, " ")
+	}
+	source := file
+	if sa.Safe {
+		file = "file:" + file
+	}
+	tpl := fmt.Sprintf(sliceM3u8FFmpegTemplate, input, file, sa.Video, sa.Audio, output, sa.HLSTime)
+	if cueArgs != nil {
+		//the hls muxer only cuts on the HLSTime grid
+		tpl = fmt.Sprintf(segmentM3u8FFmpegTemplate, input, file, sa.Video, sa.Audio, output)
+	}
+
+	ffmpeg := NewFFMpeg()
+	ffmpeg.SetArgs(tpl)
+	ffmpeg.Args = append(ffmpeg.Args, filter...)
+	if multi {
+		ffmpeg.AddArgs("-var_stream_map")
+		ffmpeg.AddArgs(sa.varStreamMap())
+	}
+	if cueArgs != nil {
+		ffmpeg.Args = append(ffmpeg.Args, cueArgs...)
+	} else {
+		if sa.gopAlign && sa.Video != "copy" {
+			ffmpeg.AddArgs("-hls_flags")
+			ffmpeg.AddArgs("independent_segments")
+		}
+		ffmpeg.AddArgs("-hls_segment_filename")
+		ffmpeg.AddArgs(sfn)
+		ffmpeg.AddArgs(playlist)
+	}
+	ffmpeg.OutPath = work
+	ffmpeg.Limits = sa.Limits
+	start := time.Now()
+	e = ffmpegRun(ctx, ffmpeg)
+	if e == nil && sa.Estimate != nil && sa.Estimate.Duration > 0 {
+		DefaultSpeedHistory.Record(sa.speedKey(), sa.Estimate.Duration.Seconds()/time.Since(start).Seconds())
+	}
+	if e == nil && cueArgs != nil {
+		e = sa.writeCues(work, filepath.Base(playlist))
+	}
+	if e == nil && len(sa.renditions("SUBTITLES")) > 0 {
+		e = sa.writeSubtitles(ctx, file, work)
+	}
+	if e == nil && sa.iframes {
+		sa.iframeBandwidth, e = sa.writeIFrames(work)
+	}
+	if e == nil && master {
+		e = sa.writeMaster(work)
+	}
+	if e == nil && sa.validate != nil {
+		e = sa.validateOutput(work)
+	}
+	if e == nil && len(sa.thumbnails) > 0 {
+		e = sa.writeThumbnails(ctx, source, work)
+	}
+	if e == nil && len(sa.sprites) > 0 {
+		e = sa.writeSprites(ctx, source, work)
+	}
+	return sa.finishSplit(work, e)
+}
+
+// finishSplit move the auto output into place and read the playlist, e is the error of the split
+func (sa *SplitArgs) finishSplit(work string, e error) (*SplitArgs, error) {
+	if sa.Auto {
+		e = finishOutput(work, sa.Output, e, sa.Debug)
+	}
+	if e != nil {
+		return nil, e
+	}
+	if sa.Playlist, e = m3u8.ReadFile(filepath.Join(sa.Output, sa.M3U8)); e != nil {
+		return nil, e
+	}
+	return sa, nil
+}
+
+// FFMpegSplitLookup check whether file is already transcoded with the settings, ffmpeg is not run
+func FFMpegSplitLookup(file string, args ...SplitOptions) (sa *SplitArgs, exist bool, e error) {
+	sa, e = prepareSplit(file, args...)
+	if e != nil {
+		return nil, false, e
+	}
+	return sa, sa.Reused, nil
+}
+
+// prepareSplit apply the options, probe the input and resolve the output directory
+func prepareSplit(file string, args ...SplitOptions) (sa *SplitArgs, e error) {
+	if strings.Index(file, " ") != -1 {
+		return nil, xerrors.New("file name cannot have spaces")
+	}
 	sa = &SplitArgs{
 		Output:          "",
 		Auto:            true,
@@ -295,24 +553,50 @@ func FFMpegSplitToM3U8(ctx Context, file string, args ...SplitOptions) (sa *Spli
 		o(sa)
 	}
 
+	if sa.Safe {
+		if e = checkSafeInput(file); e != nil {
+			return nil, e
+		}
+	}
+
 	if sa.probe != nil {
+		if sa.Safe && sa.customProbe && !sa.trustProbe {
+			return nil, xerrors.Errorf("a custom probe may open other protocols: %w", ErrUnsafeInput)
+		}
 		sa.StreamFormat, e = sa.probe(file)
 		if e != nil {
 			return nil, e
 		}
 	}
 	if sa.StreamFormat != nil {
-		video := sa.StreamFormat.Video()
-		audio := sa.StreamFormat.Audio()
-		if !sa.StreamFormat.IsVideo() || audio == nil || video == nil {
+		sel := DefaultStreamSelector()
+		if sa.Selector != nil {
+			sel = *sa.Selector
+		}
+		video := sel.Video(sa.StreamFormat)
+		audio := sel.Audio(sa.StreamFormat)
+		sa.VideoStream, sa.AudioStream = video, audio
+		//an input without video, the cover art is skipped by the selector, is split as audio only
+		if audio == nil || !(sa.StreamFormat.IsVideo() || video == nil && sa.StreamFormat.IsAudio()) {
 			return nil, xerrors.New("open file failed with ffprobe")
 		}
+		sa.AudioOnly = video == nil
+		if sa.multiAudio && !sa.AudioOnly {
+			if e = sa.audioRenditions(sel); e != nil {
+				return nil, e
+			}
+		}
+		if sa.subtitles && !sa.AudioOnly {
+			sa.subtitleRenditions(file, sel)
+		}
 
-		//check scale before codec check
-		optimizeScale(sa, video)
+		if !sa.AudioOnly {
+			//check scale before codec check
+			optimizeScale(sa, video)
 
-		if video.CodecName == "h264" && sa.Scale == 0 {
-			sa.Video = "copy"
+			if video.CodecName == "h264" && sa.Scale == 0 && sa.Burn == nil {
+				sa.Video = "copy"
+			}
 		}
 
 		if audio.CodecName == "aac" {
@@ -326,21 +610,20 @@ func FFMpegSplitToM3U8(ctx Context, file string, args ...SplitOptions) (sa *Spli
 	}
 	log.With("output", sa.Output).Info("output dir")
 	if sa.Auto {
-		sa.Output = filepath.Join(sa.Output, uuid.New().String())
-		_ = os.MkdirAll(sa.Output, os.ModePerm)
-	}
-
-	sfn := filepath.Join(sa.Output, sa.SegmentFileName)
-	m3u8 := filepath.Join(sa.Output, sa.M3U8)
-
-	tpl := fmt.Sprintf(sliceM3u8FFmpegTemplate, file, sa.Video, sa.Audio, sa.HLSTime, sfn, m3u8)
-	if sa.Scale != 0 {
-		tpl = fmt.Sprintf(sliceM3u8ScaleTemplate, file, sa.Video, sa.Audio, outputScale(sa), sa.HLSTime, sfn, m3u8)
-	}
-
-	if err := FFMpegRun(ctx, tpl); err != nil {
-		return nil, err
+		naming := sa.naming
+		if naming == nil {
+			naming = UUIDNaming
+		}
+		name, e := naming(file, sa)
+		if e != nil {
+			return nil, e
+		}
+		sa.Output = filepath.Join(sa.Output, name)
+		if _, e := os.Stat(filepath.Join(sa.Output, sa.M3U8)); e == nil {
+			sa.Reused = true
+		}
 	}
+	sa.Estimate = sa.estimate()
 	return sa, nil
 }
 
@@ -348,6 +631,10 @@ func FFMpegSplitToM3U8(ctx Context, file string, args ...SplitOptions) (sa *Spli
 func FFMpegRun(ctx Context, args string) (e error) {
 	ffmpeg := NewFFMpeg()
 	ffmpeg.SetArgs(args)
+	return ffmpegRun(ctx, ffmpeg)
+}
+
+func ffmpegRun(ctx Context, ffmpeg *Command) (e error) {
 	info := make(chan string, 1024)
 	done := make(chan error, 1)
 	go func() {
@@ -372,7 +659,14 @@ func FFMpegRun(ctx Context, args string) (e error) {
 					log.Info("exit with cancel")
 				}
 			}
-			return
+			//the output may only be cleaned up after the process exited
+			for {
+				select {
+				case <-done:
+					return
+				case <-info:
+				}
+			}
 		default:
 			//log.Println("waiting:...")
 		}
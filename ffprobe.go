@@ -26,10 +26,60 @@ const (
 
 // StreamFormat ...
 type StreamFormat struct {
-	Streams []Stream `json:"streams"`
-	Format  Format   `json:"format"`
+	Streams  []Stream  `json:"streams"`
+	Format   Format    `json:"format"`
+	Chapters []Chapter `json:"chapters,omitempty"`
+	Programs []Program `json:"programs,omitempty"`
 }
 
+// Chapter ...
+type Chapter struct {
+	ID        int64             `json:"id"`
+	TimeBase  string            `json:"time_base"`
+	Start     int64             `json:"start"`
+	StartTime string            `json:"start_time"`
+	End       int64             `json:"end"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// Program ...
+type Program struct {
+	ProgramID  int64             `json:"program_id"`
+	ProgramNum int64             `json:"program_num"`
+	NbStreams  int64             `json:"nb_streams"`
+	PmtPid     int64             `json:"pmt_pid"`
+	PcrPid     int64             `json:"pcr_pid"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Streams    []Stream          `json:"streams,omitempty"`
+}
+
+// SideData ...
+type SideData struct {
+	SideDataType  string  `json:"side_data_type"`
+	DisplayMatrix string  `json:"displaymatrix,omitempty"`
+	Rotation      *int64  `json:"rotation,omitempty"`
+	RedX          *string `json:"red_x,omitempty"`
+	RedY          *string `json:"red_y,omitempty"`
+	GreenX        *string `json:"green_x,omitempty"`
+	GreenY        *string `json:"green_y,omitempty"`
+	BlueX         *string `json:"blue_x,omitempty"`
+	BlueY         *string `json:"blue_y,omitempty"`
+	WhitePointX   *string `json:"white_point_x,omitempty"`
+	WhitePointY   *string `json:"white_point_y,omitempty"`
+	MinLuminance  *string `json:"min_luminance,omitempty"`
+	MaxLuminance  *string `json:"max_luminance,omitempty"`
+	MaxContent    *int64  `json:"max_content,omitempty"`
+	MaxAverage    *int64  `json:"max_average,omitempty"`
+}
+
+// SideData types
+const (
+	SideDataDisplayMatrix     = "Display Matrix"
+	SideDataMasteringDisplay  = "Mastering display metadata"
+	SideDataContentLightLevel = "Content light level metadata"
+)
+
 // Format ...
 type Format struct {
 	Filename       string     `json:"filename"`
@@ -47,10 +97,14 @@ type Format struct {
 
 // FormatTags ...
 type FormatTags struct {
-	MajorBrand       string `json:"major_brand"`
-	MinorVersion     string `json:"minor_version"`
-	CompatibleBrands string `json:"compatible_brands"`
-	Encoder          string `json:"encoder"`
+	MajorBrand       string            `json:"major_brand"`
+	MinorVersion     string            `json:"minor_version"`
+	CompatibleBrands string            `json:"compatible_brands"`
+	Encoder          string            `json:"encoder"`
+	Title            string            `json:"title,omitempty"`
+	CreationTime     string            `json:"creation_time,omitempty"`
+	Location         string            `json:"location,omitempty"`
+	All              map[string]string `json:"-"` //all tags
 }
 
 // Stream ...
@@ -92,6 +146,7 @@ type Stream struct {
 	NbFrames           string           `json:"nb_frames"`
 	Disposition        map[string]int64 `json:"disposition"`
 	Tags               StreamTags       `json:"tags"`
+	SideDataList       []SideData       `json:"side_data_list,omitempty"`
 	SampleFmt          *string          `json:"sample_fmt,omitempty"`
 	SampleRate         *string          `json:"sample_rate,omitempty"`
 	Channels           *int64           `json:"channels,omitempty"`
@@ -102,8 +157,131 @@ type Stream struct {
 
 // StreamTags ...
 type StreamTags struct {
-	Language    string `json:"language"`
-	HandlerName string `json:"handler_name"`
+	Language     string            `json:"language"`
+	HandlerName  string            `json:"handler_name"`
+	Title        string            `json:"title,omitempty"`
+	CreationTime string            `json:"creation_time,omitempty"`
+	Rotate       string            `json:"rotate,omitempty"`
+	All          map[string]string `json:"-"` //all tags
+}
+
+// UnmarshalJSON ...
+func (t *FormatTags) UnmarshalJSON(b []byte) error {
+	type tags FormatTags
+	if e := json.Unmarshal(b, (*tags)(t)); e != nil {
+		return e
+	}
+	return json.Unmarshal(b, &t.All)
+}
+
+// MarshalJSON ...
+func (t FormatTags) MarshalJSON() ([]byte, error) {
+	type tags FormatTags
+	return marshalTags(tags(t), t.All)
+}
+
+// UnmarshalJSON ...
+func (t *StreamTags) UnmarshalJSON(b []byte) error {
+	type tags StreamTags
+	if e := json.Unmarshal(b, (*tags)(t)); e != nil {
+		return e
+	}
+	return json.Unmarshal(b, &t.All)
+}
+
+// MarshalJSON ...
+func (t StreamTags) MarshalJSON() ([]byte, error) {
+	type tags StreamTags
+	return marshalTags(tags(t), t.All)
+}
+
+// marshalTags merge the typed tags into all tags
+func marshalTags(typed interface{}, all map[string]string) ([]byte, error) {
+	b, e := json.Marshal(typed)
+	if e != nil || len(all) == 0 {
+		return b, e
+	}
+	m := make(map[string]string, len(all))
+	for k, v := range all {
+		m[k] = v
+	}
+	if e := json.Unmarshal(b, &m); e != nil {
+		return nil, e
+	}
+	return json.Marshal(m)
+}
+
+// CreationTimeValue ...
+func (t *FormatTags) CreationTimeValue() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, t.CreationTime)
+}
+
+// CreationTimeValue ...
+func (t *StreamTags) CreationTimeValue() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, t.CreationTime)
+}
+
+// Title ...
+func (c *Chapter) Title() string {
+	return c.Tags["title"]
+}
+
+// StartValue ...
+func (c *Chapter) StartValue() (time.Duration, error) {
+	return parseSeconds(c.StartTime)
+}
+
+// EndValue ...
+func (c *Chapter) EndValue() (time.Duration, error) {
+	return parseSeconds(c.EndTime)
+}
+
+// IsDefault ...
+func (s *Stream) IsDefault() bool {
+	return s.Disposition["default"] == 1
+}
+
+// IsForced ...
+func (s *Stream) IsForced() bool {
+	return s.Disposition["forced"] == 1
+}
+
+// IsAttachedPic cover art stored as a video stream
+func (s *Stream) IsAttachedPic() bool {
+	return s.Disposition["attached_pic"] == 1
+}
+
+// SideData returns the side data of type t
+func (s *Stream) SideData(t string) *SideData {
+	for i := range s.SideDataList {
+		if s.SideDataList[i].SideDataType == t {
+			return &s.SideDataList[i]
+		}
+	}
+	return nil
+}
+
+// Rotation returns the clockwise rotation in degrees in [0,360) which displays the video upright,
+// the display matrix is counter-clockwise and is negated, the rotate tag is clockwise
+func (s *Stream) Rotation() int64 {
+	var r int64
+	if sd := s.SideData(SideDataDisplayMatrix); sd != nil && sd.Rotation != nil {
+		r = -*sd.Rotation
+	} else {
+		var e error
+		if r, e = strconv.ParseInt(s.Tags.Rotate, 10, 64); e != nil {
+			return 0
+		}
+	}
+	return (r%360 + 360) % 360
+}
+
+// IsHDR ...
+func (s *Stream) IsHDR() bool {
+	if s.SideData(SideDataMasteringDisplay) != nil || s.SideData(SideDataContentLightLevel) != nil {
+		return true
+	}
+	return s.ColorTransfer != nil && (*s.ColorTransfer == "smpte2084" || *s.ColorTransfer == "arib-std-b67")
 }
 
 //var resolution = []int{120, 144, 160, 200, 240, 320, 360, 480, 540, 576, 600, 640, 720, 768, 800, 864, 900, 960, 1024, 1050, 1080, 1152, 1200, 1280, 1440, 1536, 1600, 1620, 1800, 1824, 1920, 2048, 2160, 2400, 2560, 2880, 3072, 3200, 4096, 4320, 4800}
@@ -132,9 +310,26 @@ func getResolutionIndex(n int64, sta, end int) int {
 
 // FFProbeStreamFormat ...
 func FFProbeStreamFormat(filename string) (*StreamFormat, error) {
+	return ffprobeStreamFormat(filename, false)
+}
+
+// FFProbeStreamFormatSafe probe with only the file protocol allowed
+func FFProbeStreamFormatSafe(filename string) (*StreamFormat, error) {
+	return ffprobeStreamFormat(filename, true)
+}
+
+// probeInput returns the ffprobe input arguments of filename, only the file protocol is allowed when safe is set
+func probeInput(filename string, safe bool) []string {
+	if !safe {
+		return []string{filename}
+	}
+	return append(strings.Fields(safeInputOptions), "file:"+filename)
+}
+
+func ffprobeStreamFormat(filename string, safe bool) (*StreamFormat, error) {
 	probe := NewFFProbe()
-	probe.SetArgs("-v quiet -print_format json -show_format -show_streams")
-	probe.AddArgs(filename)
+	probe.SetArgs("-v quiet -print_format json -show_format -show_streams -show_chapters -show_programs")
+	probe.Args = append(probe.Args, probeInput(filename, safe)...)
 	s, e := probe.Run()
 	if e != nil {
 		return nil, e
@@ -149,42 +344,25 @@ func FFProbeStreamFormat(filename string) (*StreamFormat, error) {
 
 // ResolutionInt ...
 func (f *StreamFormat) ResolutionInt() int {
-	idx := 0
-	for _, s := range f.Streams {
-		if s.CodecType == "video" {
-			if s.Height != nil {
-				idx = getResolutionIndex(*s.Height, 0, -1)
-				break
-			}
-
-		}
-	}
-	return resolution[idx]
+	return resolution[f.resolutionIndex()]
 }
 
 // Resolution ...
 func (f *StreamFormat) Resolution() string {
-	idx := 0
-	for _, s := range f.Streams {
-		if s.CodecType == "video" {
-			if s.Height != nil {
-				idx = getResolutionIndex(*s.Height, 0, -1)
-				break
-			}
+	return strconv.FormatInt(int64(resolution[f.resolutionIndex()]), 10)
+}
 
-		}
+// resolutionIndex returns the resolution index of the preferred video stream, cover images are skipped
+func (f *StreamFormat) resolutionIndex() int {
+	if s := f.Video(); s != nil && s.Height != nil {
+		return getResolutionIndex(*s.Height, 0, -1)
 	}
-	return strconv.FormatInt(int64(resolution[idx]), 10)
+	return 0
 }
 
-// Video ...
+// Video returns the preferred video stream, cover images are skipped
 func (f *StreamFormat) Video() *Stream {
-	for _, s := range f.Streams {
-		if s.CodecType == "video" {
-			return &s
-		}
-	}
-	return nil
+	return DefaultStreamSelector().Video(f)
 }
 
 // IsVideo ...
@@ -192,14 +370,14 @@ func (f *StreamFormat) IsVideo() bool {
 	return isVideo(f.Format.Filename)
 }
 
-// Audio ...
+// IsAudio ...
+func (f *StreamFormat) IsAudio() bool {
+	return isAudio(f.Format.Filename)
+}
+
+// Audio returns the preferred audio stream
 func (f *StreamFormat) Audio() *Stream {
-	for _, s := range f.Streams {
-		if s.CodecType == "audio" {
-			return &s
-		}
-	}
-	return nil
+	return DefaultStreamSelector().Audio(f)
 }
 
 // NameAnalyze 解析
@@ -333,3 +511,16 @@ func isVideo(filename string) bool {
 	}
 	return false
 }
+
+func isAudio(filename string) bool {
+	alist := []string{
+		".mp3", ".m4a", ".m4b", ".aac", ".flac", ".wav", ".ogg", ".opus", ".wma",
+	}
+	ext := strings.ToLower(path.Ext(filename))
+	for _, v := range alist {
+		if ext == v {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,9 @@
 package fftool
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 )
 
 // TestFormat_NameAnalyze ...
@@ -14,3 +16,108 @@ func TestFormat_NameAnalyze(t *testing.T) {
 	}
 	t.Logf("%+v", sf1.Video())
 }
+
+const testProbeJSON = `{
+  "programs": [{"program_id": 1, "program_num": 1, "nb_streams": 2, "pmt_pid": 4096, "pcr_pid": 256, "tags": {"service_name": "Service01"}, "streams": []}],
+  "streams": [
+    {"index": 0, "codec_name": "mjpeg", "codec_type": "video", "width": 600, "height": 600, "r_frame_rate": "90000/1",
+     "disposition": {"default": 0, "attached_pic": 1}, "tags": {"comment": "Cover (front)"}},
+    {"index": 1, "codec_name": "hevc", "codec_type": "video", "width": 3840, "height": 2160, "r_frame_rate": "24000/1001",
+     "color_transfer": "smpte2084", "disposition": {"default": 1, "forced": 0},
+     "tags": {"language": "und", "rotate": "90", "title": "Main", "creation_time": "2019-05-01T10:00:00.000000Z", "vendor_id": "[0][0][0][0]"},
+     "side_data_list": [
+       {"side_data_type": "Display Matrix", "displaymatrix": "\n00000000:            0       65536           0\n", "rotation": -90},
+       {"side_data_type": "Mastering display metadata", "red_x": "35400/50000", "max_luminance": "10000000/10000"},
+       {"side_data_type": "Content light level metadata", "max_content": 1000, "max_average": 400}
+     ]},
+    {"index": 2, "codec_name": "aac", "codec_type": "audio", "channels": 2, "disposition": {"default": 1, "forced": 1},
+     "tags": {"language": "jpn", "handler_name": "SoundHandler"}}
+  ],
+  "chapters": [{"id": 0, "time_base": "1/1000", "start": 0, "start_time": "0.000000", "end": 60000, "end_time": "60.000000", "tags": {"title": "Opening"}}],
+  "format": {"filename": "video.mkv", "nb_streams": 3, "duration": "120.500000",
+    "tags": {"title": "Film", "creation_time": "2019-05-01T10:00:00.000000Z", "location": "+35.6895+139.6917/", "encoder": "Lavf58.29.100", "artist": "someone"}}
+}`
+
+// TestStreamFormat_Model ...
+func TestStreamFormat_Model(t *testing.T) {
+	sf := StreamFormat{}
+	if e := json.Unmarshal([]byte(testProbeJSON), &sf); e != nil {
+		t.Fatal(e)
+	}
+	if len(sf.Chapters) != 1 || sf.Chapters[0].Title() != "Opening" || len(sf.Programs) != 1 {
+		t.Fatalf("%+v %+v", sf.Chapters, sf.Programs)
+	}
+	if end, e := sf.Chapters[0].EndValue(); e != nil || end != 60*time.Second {
+		t.Fatal(end, e)
+	}
+	if !sf.Streams[0].IsAttachedPic() || sf.Streams[1].IsAttachedPic() {
+		t.Fatal("attached pic")
+	}
+	hevc := sf.Streams[1]
+	if hevc.Rotation() != 90 || !hevc.IsHDR() || !hevc.IsDefault() {
+		t.Fatal(hevc.Rotation(), hevc.IsHDR(), hevc.IsDefault())
+	}
+	if cll := hevc.SideData(SideDataContentLightLevel); cll == nil || *cll.MaxContent != 1000 {
+		t.Fatal(cll)
+	}
+	if hevc.Tags.Title != "Main" || hevc.Tags.Rotate != "90" || hevc.Tags.All["vendor_id"] != "[0][0][0][0]" {
+		t.Fatalf("%+v", hevc.Tags)
+	}
+	if !sf.Streams[2].IsForced() || sf.Streams[2].Tags.Language != "jpn" {
+		t.Fatalf("%+v", sf.Streams[2])
+	}
+	//the resolution is of the main video, not the cover image
+	if sf.ResolutionInt() != 2560 || sf.Resolution() != "2560" {
+		t.Fatal(sf.ResolutionInt(), sf.Resolution())
+	}
+	tags := sf.Format.Tags
+	if tags.Title != "Film" || tags.Location != "+35.6895+139.6917/" || tags.All["artist"] != "someone" {
+		t.Fatalf("%+v", tags)
+	}
+	if ct, e := tags.CreationTimeValue(); e != nil || ct.Year() != 2019 {
+		t.Fatal(ct, e)
+	}
+
+	b, e := json.Marshal(sf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	sf2 := StreamFormat{}
+	if e := json.Unmarshal(b, &sf2); e != nil {
+		t.Fatal(e)
+	}
+	if sf2.Format.Tags.All["artist"] != "someone" || sf2.Streams[1].Tags.All["vendor_id"] == "" {
+		t.Fatal("tags lost after marshal", string(b))
+	}
+}
+
+// TestStream_Rotation ...
+func TestStream_Rotation(t *testing.T) {
+	matrix := func(r int64) *Stream {
+		return &Stream{SideDataList: []SideData{{SideDataType: SideDataDisplayMatrix, Rotation: &r}}}
+	}
+	tag := func(r string) *Stream {
+		s := &Stream{}
+		s.Tags.Rotate = r
+		return s
+	}
+	//the same rotation from the two sources
+	for _, c := range []struct {
+		matrix *Stream
+		tag    *Stream
+		want   int64
+	}{
+		{matrix(-90), tag("90"), 90},
+		{matrix(90), tag("270"), 270},
+		{matrix(-180), tag("180"), 180},
+		{matrix(180), tag("-180"), 180},
+		{matrix(0), tag(""), 0},
+	} {
+		if r := c.matrix.Rotation(); r != c.want {
+			t.Errorf("display matrix: %d != %d", r, c.want)
+		}
+		if r := c.tag.Rotation(); r != c.want {
+			t.Errorf("rotate tag %q: %d != %d", c.tag.Tags.Rotate, r, c.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package fftool
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// GOPAlignOption align the keyframes of a re-encoded video to the HLSTime grid: a keyframe is forced at
+// every boundary, scene cut keyframes are disabled and the GOPs are closed, so the segments of every
+// encode of the input have the same boundaries and exact durations. A copied video is not changed
+func GOPAlignOption(b bool) SplitOptions {
+	return func(args *SplitArgs) {
+		args.gopAlign = b
+	}
+}
+
+// gopFrames returns the frames of a HLSTime GOP, 0 when the frame rate is unknown. A fractional count
+// is rounded up so -g never puts a keyframe just before the forced one
+func (sa *SplitArgs) gopFrames() int64 {
+	rate := sa.FrameRate
+	if rate.IsZero() && sa.VideoStream != nil {
+		if r, e := sa.VideoStream.FrameRate(); e == nil {
+			rate = r
+		}
+	}
+	//the tolerance keeps an exact count from rounding up on a float error
+	return int64(math.Ceil(rate.Float64()*float64(sa.HLSTime) - 1e-9))
+}
+
+// gopArgs returns the encoder arguments of the aligned keyframes, the keyframes
+// are only forced on the grid when force is set
+func (sa *SplitArgs) gopArgs(force bool) string {
+	var args []string
+	if force {
+		args = append(args, fmt.Sprintf("-force_key_frames expr:gte(t,n_forced*%d)", sa.HLSTime))
+	}
+	args = append(args, "-sc_threshold 0 -flags +cgop")
+	if n := sa.gopFrames(); n > 0 {
+		//no keyframe between the forced ones
+		args = append(args, fmt.Sprintf("-g %d -keyint_min %d", n, n))
+	}
+	return strings.Join(args, " ")
+}
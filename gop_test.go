@@ -0,0 +1,55 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// TestSplitArgs_GOPArgs ...
+func TestSplitArgs_GOPArgs(t *testing.T) {
+	sa := &SplitArgs{HLSTime: 4, VideoStream: &Stream{RFrameRate: "30000/1001"}}
+	if args := sa.gopArgs(true); args != "-force_key_frames expr:gte(t,n_forced*4) -sc_threshold 0 -flags +cgop -g 120 -keyint_min 120" {
+		t.Fatal(args)
+	}
+	sa.FrameRate = Rational{Num: 25, Den: 1}
+	if args := sa.gopArgs(false); args != "-sc_threshold 0 -flags +cgop -g 100 -keyint_min 100" {
+		t.Fatal(args)
+	}
+	//250.4 frames in a segment
+	sa = &SplitArgs{HLSTime: 10, FrameRate: Rational{Num: 2504, Den: 100}}
+	if n := sa.gopFrames(); n != 251 {
+		t.Fatal(n)
+	}
+	sa = &SplitArgs{HLSTime: 4}
+	if args := sa.gopArgs(true); args != "-force_key_frames expr:gte(t,n_forced*4) -sc_threshold 0 -flags +cgop" {
+		t.Fatal(args)
+	}
+}
+
+// TestFFMpegSplitToM3U8_GOPAlign ...
+func TestFFMpegSplitToM3U8_GOPAlign(t *testing.T) {
+	dir, logFile, cleanup := testLogDir(t, "gop")
+	defer cleanup()
+	defer fakeCommand(t, "ffmpeg", fakePlaylistFFmpeg)()
+	sf := testVideoFormat(t)
+	sf.Streams[0].RFrameRate = "25/1" //the 720p output is 24000/1001
+
+	for _, scale := range []int64{720, 0} {
+		_, e := FFMpegSplitToM3U8(nil, "video.mkv", StreamFormatOption(sf), AutoOption(false), OutputOption(dir), HLSTimeOption(2),
+			ScaleOption(scale), GOPAlignOption(true))
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	b, _ := ioutil.ReadFile(logFile)
+	runs := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if !strings.Contains(runs[0], "-force_key_frames expr:gte(t,n_forced*2) -sc_threshold 0 -flags +cgop -g 48 -keyint_min 48") ||
+		!strings.Contains(runs[0], "-hls_time 2 -vf scale=-2:720 -hls_flags independent_segments -hls_segment_filename") {
+		t.Fatal(runs[0])
+	}
+	//a copied video keeps its keyframes
+	if strings.Contains(runs[1], "-force_key_frames") || strings.Contains(runs[1], "independent_segments") {
+		t.Fatal(runs[1])
+	}
+}
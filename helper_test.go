@@ -0,0 +1,66 @@
+package fftool
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeLog the first line of a fake which records the arguments of every run as a line of $FAKE_LOG
+const fakeLog = `echo "$@" >> "$FAKE_LOG"
+`
+
+// fakePlaylistFFmpeg record the arguments and write a playlist with an ffmpeg date to the last argument, then exits like a dropped live input
+const fakePlaylistFFmpeg = fakeLog + `for out; do :; done
+printf '#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000+0000\n#EXTINF:2.000000,\nmedia-00000.ts\n' > "$out"
+`
+
+// fakeCommand put a shell script named name in front of PATH
+func fakeCommand(t *testing.T, name string, script string) func() {
+	t.Helper()
+	dir, e := ioutil.TempDir("", "fftool-bin")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); e != nil {
+		t.Fatal(e)
+	}
+	path := os.Getenv("PATH")
+	_ = os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() {
+		_ = os.Setenv("PATH", path)
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// testLogDir returns a temporary directory and the $FAKE_LOG file in it, cleanup removes both
+func testLogDir(t *testing.T, prefix string) (dir string, logFile string, cleanup func()) {
+	t.Helper()
+	dir, e := ioutil.TempDir("", prefix)
+	if e != nil {
+		t.Fatal(e)
+	}
+	logFile = filepath.Join(dir, "ffmpeg.log")
+	_ = os.Setenv("FAKE_LOG", logFile)
+	return dir, logFile, func() {
+		_ = os.Unsetenv("FAKE_LOG")
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// testVideoFormat returns the format of a 1080p video with an audio and two subtitle streams
+func testVideoFormat(t *testing.T) *StreamFormat {
+	sf := StreamFormat{}
+	e := json.Unmarshal([]byte(`{"streams": [
+		{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080},
+		{"index": 1, "codec_type": "audio", "codec_name": "aac"},
+		{"index": 2, "codec_type": "subtitle", "codec_name": "subrip", "tags": {"language": "eng"}},
+		{"index": 3, "codec_type": "subtitle", "codec_name": "hdmv_pgs_subtitle", "tags": {"language": "jpn"}}
+	], "format": {"filename": "video.mkv"}}`), &sf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return &sf
+}
@@ -0,0 +1,162 @@
+package fftool
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+const (
+	id3PMTPID  = 0x1000
+	id3PID     = 0x100
+	tsPacket   = 188
+	id3Program = 1
+)
+
+// TimedMetadata an ID3 tag shown at Time of the audio, Text is written as TXXX frames
+type TimedMetadata struct {
+	Time   time.Duration
+	Title  string
+	Artist string
+	Text   map[string]string
+}
+
+// id3Tag returns the ID3v2.4 tag of m with UTF-8 text frames
+func id3Tag(m TimedMetadata) []byte {
+	var frames bytes.Buffer
+	frame := func(id string, data []byte) {
+		frames.WriteString(id)
+		frames.Write(syncsafe(len(data)))
+		frames.Write([]byte{0, 0})
+		frames.Write(data)
+	}
+	text := func(id string, values ...string) {
+		data := []byte{3} //UTF-8
+		for i, v := range values {
+			if i > 0 {
+				data = append(data, 0)
+			}
+			data = append(data, v...)
+		}
+		frame(id, data)
+	}
+	if m.Title != "" {
+		text("TIT2", m.Title)
+	}
+	if m.Artist != "" {
+		text("TPE1", m.Artist)
+	}
+	var keys []string
+	for k := range m.Text {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		text("TXXX", k, m.Text[k])
+	}
+	tag := append([]byte{'I', 'D', '3', 4, 0, 0}, syncsafe(frames.Len())...)
+	return append(tag, frames.Bytes()...)
+}
+
+// syncsafe returns the 4 byte syncsafe integer of n
+func syncsafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+// tsWriter write a MPEG-TS stream carrying timed ID3 metadata only, ffmpeg reads it as a
+// timed_id3 data stream which the mpegts and hls muxers copy with the metadata descriptors
+type tsWriter struct {
+	buf bytes.Buffer
+	cc  map[int]byte
+}
+
+// packet write a TS packet of pid, the payload is padded with adaptation field stuffing
+func (w *tsWriter) packet(pid int, start bool, payload []byte) {
+	if w.cc == nil {
+		w.cc = make(map[int]byte)
+	}
+	header := []byte{0x47, byte(pid >> 8 & 0x1f), byte(pid), 0x10 | w.cc[pid]&0x0f}
+	if start {
+		header[1] |= 0x40
+	}
+	w.cc[pid]++
+	n := tsPacket - len(header)
+	if len(payload) > n {
+		payload, n = payload[:n], 0
+	} else {
+		n -= len(payload)
+	}
+	w.buf.Write(header[:3])
+	if n == 0 {
+		w.buf.WriteByte(header[3])
+		w.buf.Write(payload)
+		return
+	}
+	//adaptation field with stuffing
+	w.buf.WriteByte(header[3] | 0x20)
+	w.buf.WriteByte(byte(n - 1))
+	if n > 1 {
+		w.buf.WriteByte(0)
+		w.buf.Write(bytes.Repeat([]byte{0xff}, n-2))
+	}
+	w.buf.Write(payload)
+}
+
+// section write a PSI section of pid with its CRC
+func (w *tsWriter) section(pid int, tableID byte, id int, body []byte) {
+	s := []byte{tableID, 0xb0, 0, byte(id >> 8), byte(id), 0xc1, 0, 0}
+	s = append(s, body...)
+	n := len(s) - 3 + 4
+	s[1] |= byte(n >> 8 & 0x0f)
+	s[2] = byte(n)
+	crc := crc32MPEG2(s)
+	s = append(s, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+	w.packet(pid, true, append([]byte{0}, s...))
+}
+
+// tables write the PAT and the PMT of the metadata stream
+func (w *tsWriter) tables() {
+	w.section(0, 0x00, 1, []byte{0, id3Program, 0xe0 | id3PMTPID>>8, id3PMTPID & 0xff})
+	pointer := []byte{0x25, 15, 0xff, 0xff, 'I', 'D', '3', ' ', 0xff, 'I', 'D', '3', ' ', 0, 0x1f, 0, 0x01}
+	metadata := []byte{0x26, 13, 0xff, 0xff, 'I', 'D', '3', ' ', 0xff, 'I', 'D', '3', ' ', 0, 0x0f}
+	pmt := []byte{0xe0 | 0x1f, 0xff, 0xf0, byte(len(pointer))}
+	pmt = append(pmt, pointer...)
+	pmt = append(pmt, 0x15, 0xe0|id3PID>>8, id3PID&0xff, 0xf0, byte(len(metadata)))
+	pmt = append(pmt, metadata...)
+	w.section(id3PMTPID, 0x02, id3Program, pmt)
+}
+
+// pes write the PES packet of an ID3 tag at pts
+func (w *tsWriter) pes(pts time.Duration, tag []byte) {
+	v := pts90k(pts)
+	header := []byte{0, 0, 1, 0xbd, 0, 0, 0x84, 0x80, 5,
+		byte(0x21 | v>>29&0x0e), byte(v >> 22), byte(v>>14 | 1), byte(v >> 7), byte(v<<1 | 1)}
+	n := len(header) - 6 + len(tag)
+	header[4], header[5] = byte(n>>8), byte(n)
+	payload := append(header, tag...)
+	for start := true; len(payload) > 0; start = false {
+		size := tsPacket - 4
+		if size > len(payload) {
+			size = len(payload)
+		}
+		w.packet(id3PID, start, payload[:size])
+		payload = payload[size:]
+	}
+}
+
+// writeID3TS write the timed metadata as a MPEG-TS file, a tag is added at 0 so ffmpeg
+// does not shift the timestamps of the first tag to the start
+func writeID3TS(path string, metadata []TimedMetadata) error {
+	metadata = append([]TimedMetadata(nil), metadata...)
+	sort.SliceStable(metadata, func(i, j int) bool { return metadata[i].Time < metadata[j].Time })
+	if len(metadata) == 0 || metadata[0].Time > 0 {
+		metadata = append([]TimedMetadata{{}}, metadata...)
+	}
+	w := &tsWriter{}
+	for _, m := range metadata {
+		w.tables()
+		w.pes(m.Time, id3Tag(m))
+	}
+	return ioutil.WriteFile(path, w.buf.Bytes(), 0644)
+}
@@ -0,0 +1,66 @@
+package fftool
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestID3Tag ...
+func TestID3Tag(t *testing.T) {
+	tag := id3Tag(TimedMetadata{Title: "Episode", Text: map[string]string{"chapter": "2"}})
+	want := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x26"), "TIT2\x00\x00\x00\x08\x00\x00\x03Episode"...)
+	want = append(want, "TXXX\x00\x00\x00\x0a\x00\x00\x03chapter\x002"...)
+	if !bytes.Equal(tag, want) {
+		t.Fatalf("%q", tag)
+	}
+	if s := syncsafe(300); !bytes.Equal(s, []byte{0, 0, 2, 0x2c}) {
+		t.Fatal(s)
+	}
+}
+
+// TestWriteID3TS ...
+func TestWriteID3TS(t *testing.T) {
+	dir, e := ioutil.TempDir("", "id3")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metadata.ts")
+	if e := writeID3TS(path, []TimedMetadata{{Time: 90 * time.Second, Title: "b"}, {Time: 30 * time.Second, Title: "a"}}); e != nil {
+		t.Fatal(e)
+	}
+	b, _ := ioutil.ReadFile(path)
+	//PAT, PMT and PES of the tags at 0, 30s and 90s
+	if len(b) != 9*tsPacket {
+		t.Fatal(len(b))
+	}
+	for i := 0; i < len(b); i += tsPacket {
+		p := b[i : i+tsPacket]
+		pid := int(p[1]&0x1f)<<8 | int(p[2])
+		if p[0] != 0x47 {
+			t.Fatal(i)
+		}
+		payload := p[4:]
+		if p[3]&0x20 != 0 {
+			payload = payload[1+int(p[4]):]
+		}
+		switch pid {
+		case 0, id3PMTPID:
+			section := payload[1:]
+			if n := int(section[1]&0x0f)<<8 | int(section[2]); crc32MPEG2(section[:n+3]) != 0 {
+				t.Fatal("crc", pid)
+			}
+		case id3PID:
+			pts := int64(payload[9]&0x0e)<<29 | int64(payload[10])<<22 | int64(payload[11]&0xfe)<<14 | int64(payload[12])<<7 | int64(payload[13])>>1
+			if want := []int64{0, 30, 90}[i/(3*tsPacket)] * 90000; pts != want || !bytes.Equal(payload[14:17], []byte("ID3")) {
+				t.Fatal(pts, want)
+			}
+		default:
+			t.Fatal(pid)
+		}
+	}
+}
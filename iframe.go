@@ -0,0 +1,123 @@
+package fftool
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+)
+
+const variantIFrame = "iframe"
+
+// IFrameOption write a byte range I-frame playlist of the video for trick play,
+// sa.M3U8 is a master playlist then
+func IFrameOption(b bool) SplitOptions {
+	return func(args *SplitArgs) {
+		args.iframes = b
+	}
+}
+
+// iframe a keyframe of a segment
+type iframe struct {
+	URI      string
+	Time     time.Duration
+	Duration time.Duration
+	Offset   int64
+	Length   int64
+}
+
+// master reports whether sa.M3U8 is a master playlist
+func (sa *SplitArgs) master() bool {
+	return len(sa.Renditions) > 0 || sa.iframes
+}
+
+// iframePlaylist returns the I-frame playlist of the video
+func (sa *SplitArgs) iframePlaylist() string {
+	return variantName(variantIFrame, sa.M3U8)
+}
+
+// segmentIFrames returns the keyframes of the segment at path as byte ranges,
+// a range ends at the next video packet and the first one starts at 0 to include PAT/PMT
+func segmentIFrames(path string, uri string, safe bool) ([]iframe, error) {
+	var frames []iframe
+	e := ffprobePackets(path, "V:0", safe, func(p Packet) error {
+		if n := len(frames); n > 0 && frames[n-1].Length == 0 && p.Pos > frames[n-1].Offset {
+			frames[n-1].Length = p.Pos - frames[n-1].Offset
+		}
+		if !p.Keyframe() || p.Pos < 0 {
+			return nil
+		}
+		f := iframe{URI: uri, Time: p.PTS, Offset: p.Pos}
+		if len(frames) == 0 {
+			f.Offset = 0
+		}
+		frames = append(frames, f)
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	if n := len(frames); n > 0 && frames[n-1].Length == 0 {
+		info, e := os.Stat(path)
+		if e != nil {
+			return nil, e
+		}
+		frames[n-1].Length = info.Size() - frames[n-1].Offset
+	}
+	return frames, nil
+}
+
+// writeIFrames write the I-frame playlist of the video segments in dir, returns the peak bandwidth
+func (sa *SplitArgs) writeIFrames(dir string) (int64, error) {
+	video, e := readMediaPlaylist(filepath.Join(dir, sa.videoPlaylist()))
+	if e != nil {
+		return 0, e
+	}
+	var frames []iframe
+	for _, seg := range video.Segments {
+		f, e := segmentIFrames(filepath.Join(dir, seg.URI), seg.URI, sa.Safe)
+		if e != nil {
+			return 0, e
+		}
+		frames = append(frames, f...)
+	}
+	total := video.Duration()
+	for i := range frames {
+		if i+1 < len(frames) {
+			frames[i].Duration = frames[i+1].Time - frames[i].Time
+		} else {
+			frames[i].Duration = total - (frames[i].Time - frames[0].Time)
+		}
+	}
+	return writeIFramePlaylist(filepath.Join(dir, sa.iframePlaylist()), frames)
+}
+
+// writeIFramePlaylist write the frames to path, returns the peak bandwidth
+func writeIFramePlaylist(path string, frames []iframe) (int64, error) {
+	list := &m3u8.MediaPlaylist{
+		Version:      4,
+		PlaylistType: "VOD",
+		IFramesOnly:  true,
+		EndList:      true,
+	}
+	var bandwidth int64
+	for _, f := range frames {
+		if f.Duration <= 0 {
+			continue
+		}
+		if b := int64(float64(f.Length*8) / f.Duration.Seconds()); b > bandwidth {
+			bandwidth = b
+		}
+		list.Segments = append(list.Segments, &m3u8.Segment{
+			URI:       f.URI,
+			Duration:  f.Duration,
+			ByteRange: &m3u8.ByteRange{Length: f.Length, Offset: f.Offset},
+		})
+	}
+	list.TargetDuration = list.MaxDuration()
+	if list.TargetDuration == 0 {
+		list.TargetDuration = 1
+	}
+	return bandwidth, m3u8.WriteFile(path, list)
+}
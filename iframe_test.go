@@ -0,0 +1,68 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeIFrameProbe print the video packets of the segment named by the last argument
+const fakeIFrameProbe = `for last; do :; done
+case "$last" in
+*00000.ts) echo '{"packets": [
+	{"stream_index": 0, "pts_time": "1.400000", "size": "3000", "pos": "376", "flags": "K_"},
+	{"stream_index": 0, "pts_time": "1.440000", "size": "200", "pos": "4136", "flags": "__"},
+	{"stream_index": 0, "pts_time": "3.400000", "size": "2000", "pos": "6016", "flags": "K_"},
+	{"stream_index": 0, "pts_time": "3.440000", "size": "200", "pos": "8648", "flags": "__"}]}' ;;
+*) echo '{"packets": [
+	{"stream_index": 0, "pts_time": "5.400000", "size": "1000", "pos": "376", "flags": "K_"}]}' ;;
+esac
+`
+
+// TestSplitArgs_WriteIFrames ...
+func TestSplitArgs_WriteIFrames(t *testing.T) {
+	defer fakeCommand(t, "ffprobe", fakeIFrameProbe)()
+	dir, e := ioutil.TempDir("", "iframe")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	sa := &SplitArgs{M3U8: "media.m3u8"}
+	IFrameOption(true)(sa)
+	if sa.videoPlaylist() != "video_media.m3u8" {
+		t.Fatal(sa.videoPlaylist())
+	}
+	_ = ioutil.WriteFile(filepath.Join(dir, sa.videoPlaylist()), []byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.000000,\nvideo_media-00000.ts\n#EXTINF:2.000000,\nvideo_media-00001.ts\n#EXT-X-ENDLIST\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "video_media-00000.ts"), make([]byte, 18800), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "video_media-00001.ts"), make([]byte, 1880), 0644)
+
+	bandwidth, e := sa.writeIFrames(dir)
+	if e != nil {
+		t.Fatal(e)
+	}
+	list, e := ioutil.ReadFile(filepath.Join(dir, "iframe_media.m3u8"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	want := "#EXT-X-I-FRAMES-ONLY\n" +
+		"#EXTINF:2.000000,\n#EXT-X-BYTERANGE:4136@0\nvideo_media-00000.ts\n" +
+		"#EXTINF:2.000000,\n#EXT-X-BYTERANGE:2632@6016\nvideo_media-00000.ts\n" +
+		"#EXTINF:2.000000,\n#EXT-X-BYTERANGE:1880@0\nvideo_media-00001.ts\n#EXT-X-ENDLIST\n"
+	if !strings.HasSuffix(string(list), want) || !strings.Contains(string(list), "#EXT-X-TARGETDURATION:2\n") {
+		t.Fatal(string(list))
+	}
+	if bandwidth != 4136*8/2 {
+		t.Fatal(bandwidth)
+	}
+
+	sa.iframeBandwidth = bandwidth
+	if e := sa.writeMaster(dir); e != nil {
+		t.Fatal(e)
+	}
+	master, _ := ioutil.ReadFile(filepath.Join(dir, "media.m3u8"))
+	if !strings.Contains(string(master), "video_media.m3u8\n#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=16544,URI=\"iframe_media.m3u8\"\n") {
+		t.Fatal(string(master))
+	}
+}
@@ -0,0 +1,269 @@
+package fftool
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+	"golang.org/x/xerrors"
+)
+
+const jitSegmentTemplate = "segment-%05d.ts"
+
+// JITHandler serve a virtual HLS playlist of a source file, every segment is transcoded on its first
+// request and cached on disk. A re-encoded video is cut on the HLSTime grid, a copied video on the
+// keyframes which are scanned in the background, the playlist grows as an EVENT playlist meanwhile
+type JITHandler struct {
+	File     string
+	Args     *SplitArgs
+	Prefetch int //segments transcoded ahead of a request
+	points   []time.Duration
+	complete bool //all the cut points are known
+	served   bool //the playlist was served before it was complete
+	scanned  chan struct{}
+	duration time.Duration
+	ctx      Context
+	mu       sync.Mutex
+	jobs     map[int]*jitJob
+}
+
+// jitJob a running segment transcode, done is closed with e set
+type jitJob struct {
+	done chan struct{}
+	e    error
+}
+
+// NewJITHandler probe file and start computing the segments, the cache is the auto output directory
+// which is named by the content unless NamingOption is set
+func NewJITHandler(file string, args ...SplitOptions) (*JITHandler, error) {
+	args = append([]SplitOptions{NamingOption(ContentNaming)}, args...)
+	args = append(args, probeOption())
+	sa, e := prepareSplit(file, args...)
+	if e != nil {
+		return nil, e
+	}
+	duration, e := sa.StreamFormat.Format.DurationValue()
+	if e != nil {
+		return nil, e
+	}
+	if e := os.MkdirAll(sa.Output, os.ModePerm); e != nil {
+		return nil, e
+	}
+	h := &JITHandler{
+		File:     file,
+		Args:     sa,
+		Prefetch: 1,
+		duration: duration,
+		ctx:      FFmpegContext(),
+		jobs:     make(map[int]*jitJob),
+		points:   []time.Duration{0},
+		scanned:  make(chan struct{}),
+	}
+	if sa.Video != "copy" {
+		h.points = h.gridPoints()
+		h.finishScan()
+		return h, nil
+	}
+	go h.scan()
+	return h, nil
+}
+
+// gridPoints returns the segment start times on the HLSTime grid
+func (h *JITHandler) gridPoints() []time.Duration {
+	interval := time.Duration(h.Args.HLSTime) * time.Second
+	points := []time.Duration{0}
+	for t := interval; t < h.duration; t += interval {
+		points = append(points, t)
+	}
+	return points
+}
+
+// scan add the keyframes at least HLSTime apart as cut points while ffprobe reads the packets,
+// the first segment always starts at 0
+func (h *JITHandler) scan() {
+	defer h.finishScan()
+	interval := time.Duration(h.Args.HLSTime) * time.Second
+	e := ffprobePackets(h.File, "V:0", h.Args.Safe, func(p Packet) error {
+		if e := h.ctx.Context().Err(); e != nil {
+			return e
+		}
+		if !p.Keyframe() {
+			return nil
+		}
+		h.mu.Lock()
+		if p.PTS-h.points[len(h.points)-1] >= interval {
+			h.points = append(h.points, p.PTS)
+		}
+		h.mu.Unlock()
+		return nil
+	})
+	if e != nil {
+		//the last known point runs to the end
+		log.With("file", h.File).Error(e)
+	}
+}
+
+func (h *JITHandler) finishScan() {
+	h.mu.Lock()
+	h.complete = true
+	h.mu.Unlock()
+	close(h.scanned)
+}
+
+// Close cancel the running transcodes and the keyframe scan
+func (h *JITHandler) Close() {
+	h.ctx.Cancel()
+}
+
+// segments returns the count of the segments which are known to end
+func (h *JITHandler) segments() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.complete {
+		return len(h.points)
+	}
+	return len(h.points) - 1
+}
+
+// segment returns the start and the duration of segment i
+func (h *JITHandler) segment(i int) (time.Duration, time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	end := h.duration
+	if i+1 < len(h.points) {
+		end = h.points[i+1]
+	}
+	return h.points[i], end - h.points[i]
+}
+
+// Playlist returns the VOD playlist of all segments, while the keyframes are scanned
+// it is an EVENT playlist of the known segments
+func (h *JITHandler) Playlist() *m3u8.MediaPlaylist {
+	n := h.segments()
+	h.mu.Lock()
+	complete := h.complete
+	if !complete {
+		h.served = true
+	}
+	p := &m3u8.MediaPlaylist{Version: 3, PlaylistType: "VOD", EndList: complete}
+	if h.served {
+		//the type of a playlist a client has loaded does not change
+		p.PlaylistType = "EVENT"
+	}
+	h.mu.Unlock()
+	for i := 0; i < n; i++ {
+		_, d := h.segment(i)
+		p.Segments = append(p.Segments, &m3u8.Segment{URI: fmt.Sprintf(jitSegmentTemplate, i), Duration: d})
+	}
+	p.TargetDuration = p.MaxDuration()
+	if !complete && p.TargetDuration < int(h.Args.HLSTime) {
+		p.TargetDuration = int(h.Args.HLSTime)
+	}
+	return p
+}
+
+// ServeHTTP serve the playlist as Args.M3U8 and the segments
+func (h *JITHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(path.Clean("/" + r.URL.Path))
+	if name == h.Args.M3U8 {
+		var buf bytes.Buffer
+		if e := h.Playlist().Encode(&buf); e != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+	var i int
+	if _, e := fmt.Sscanf(name, jitSegmentTemplate, &i); e != nil || i < 0 || i >= h.segments() || name != fmt.Sprintf(jitSegmentTemplate, i) {
+		http.NotFound(w, r)
+		return
+	}
+	file, e := h.Segment(i)
+	if e != nil {
+		log.With("segment", i).Error(e)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	for n := 1; n <= h.Prefetch && i+n < h.segments(); n++ {
+		go func(n int) { _, _ = h.Segment(n) }(i + n)
+	}
+	w.Header().Set("Content-Type", "video/mp2t")
+	http.ServeFile(w, r, file)
+}
+
+// Segment returns the cached segment i, it is transcoded once when it is not cached
+func (h *JITHandler) Segment(i int) (string, error) {
+	if i < 0 || i >= h.segments() {
+		return "", xerrors.Errorf("segment out of range:%d", i)
+	}
+	file := filepath.Join(h.Args.Output, fmt.Sprintf(jitSegmentTemplate, i))
+	if _, e := os.Stat(file); e == nil {
+		return file, nil
+	}
+	h.mu.Lock()
+	job, running := h.jobs[i]
+	if !running {
+		job = &jitJob{done: make(chan struct{})}
+		h.jobs[i] = job
+	}
+	h.mu.Unlock()
+	if !running {
+		job.e = h.transcode(i, file)
+		h.mu.Lock()
+		delete(h.jobs, i)
+		h.mu.Unlock()
+		close(job.done)
+	}
+	<-job.done
+	if job.e != nil {
+		return "", job.e
+	}
+	return file, nil
+}
+
+// transcode write segment i to file through a temporary file, the timestamps continue from the previous segment
+func (h *JITHandler) transcode(i int, file string) error {
+	sa := h.Args
+	start, d := h.segment(i)
+	filter, videoMap, e := sa.videoFilterArgs(h.File)
+	if e != nil {
+		return e
+	}
+	input := h.File
+	args := []string{"-y"}
+	if sa.Safe {
+		args = append(args, strings.Fields(safeInputOptions)...)
+		input = "file:" + input
+	}
+	in, out := sa.Limits.args()
+	args = append(args, strings.Fields(in)...)
+	args = append(args, "-ss", fmt.Sprintf("%.6f", start.Seconds()), "-i", input, "-t", fmt.Sprintf("%.6f", d.Seconds()))
+	args = append(args, filter...)
+	args = append(args, strings.Fields(videoMap)...)
+	args = append(args, strings.Fields(mapArgs(sa.AudioStream))...)
+	args = append(args, "-c:v", sa.Video, "-c:a", sa.Audio)
+	if sa.Scale != 0 {
+		args = append(args, strings.Fields(outputScale(sa))...)
+	}
+	args = append(args, strings.Fields(out)...)
+	tmp := file + ".tmp"
+	args = append(args, "-output_ts_offset", fmt.Sprintf("%.6f", start.Seconds()), "-muxdelay", "0", "-f", "mpegts", tmp)
+	ffmpeg := NewFFMpeg()
+	ffmpeg.Args = args
+	ffmpeg.Limits = sa.Limits
+	//a cancelled ffmpeg has exited when ffmpegRun returns, so the partial file is not written again
+	if e := ffmpegRun(h.ctx, ffmpeg); e != nil {
+		_ = os.Remove(tmp)
+		return e
+	}
+	return os.Rename(tmp, file)
+}
@@ -0,0 +1,165 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJITProbe print the packets for the keyframe probe and the stream format otherwise
+const fakeJITProbe = `case "$*" in
+*show_entries*) cat <<'JSON'
+` + testPacketsJSON + `
+JSON
+;;
+*) echo '{"streams": [
+	{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1280, "height": 720},
+	{"index": 1, "codec_type": "audio", "codec_name": "aac"}
+], "format": {"filename": "video.mp4", "duration": "6.000000"}}' ;;
+esac
+`
+
+// TestJITHandler ...
+func TestJITHandler(t *testing.T) {
+	dir, logFile, cleanup := testLogDir(t, "jit")
+	defer cleanup()
+	file := filepath.Join(dir, "video.mp4")
+	_ = ioutil.WriteFile(file, []byte("video"), 0644)
+	defer fakeCommand(t, "ffprobe", fakeJITProbe)()
+	defer fakeCommand(t, "ffmpeg", fakeThumbnailFFmpeg)()
+
+	h, e := NewJITHandler(file, OutputOption(filepath.Join(dir, "cache")), HLSTimeOption(2))
+	if e != nil {
+		t.Fatal(e)
+	}
+	h.Prefetch = 0
+	defer h.Close()
+	<-h.scanned
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/video/media.m3u8", nil))
+	for _, want := range []string{"#EXT-X-TARGETDURATION:3\n", "#EXTINF:2.002000,\nsegment-00000.ts\n", "#EXTINF:1.996000,\nsegment-00002.ts\n#EXT-X-ENDLIST\n"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("missing %q in\n%s", want, w.Body.String())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/video/segment-00001.ts", nil))
+	if w.Code != 200 {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	b, _ := ioutil.ReadFile(logFile)
+	args := string(b)
+	if !strings.Contains(args, "-ss 2.002000 -i "+file+" -t 2.002000 -map 0:0 -map 0:1 -c:v copy -c:a copy") ||
+		!strings.Contains(args, "-output_ts_offset 2.002000 -muxdelay 0 -f mpegts") {
+		t.Fatal(args)
+	}
+
+	//cached
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/video/segment-00001.ts", nil))
+	b, _ = ioutil.ReadFile(logFile)
+	if w.Code != 200 || strings.Count(string(b), "\n") != 1 {
+		t.Fatal(w.Code, string(b))
+	}
+
+	for _, p := range []string{"/video/segment-00003.ts", "/video/segment-1.ts", "/video/other.m3u8"} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+		if w.Code != 404 {
+			t.Fatal(p, w.Code)
+		}
+	}
+	if _, d := h.segment(2); d != 1996*time.Millisecond {
+		t.Fatal(d)
+	}
+}
+
+// fakeJITGatedProbe print the packets up to the second keyframe, then the rest once $JIT_GATE exists
+const fakeJITGatedProbe = `case "$*" in
+*show_entries*) echo '{"packets": [
+	{"stream_index": 0, "pts_time": "0.083417", "flags": "K_"},
+	{"stream_index": 0, "pts_time": "2.085417", "flags": "K_"},'
+while [ ! -f "$JIT_GATE" ]; do sleep 0.05; done
+echo '{"stream_index": 0, "pts_time": "4.087417", "flags": "K_"}]}'
+;;
+*) echo '{"streams": [
+	{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1280, "height": 720},
+	{"index": 1, "codec_type": "audio", "codec_name": "aac"}
+], "format": {"filename": "video.mp4", "duration": "6.000000"}}' ;;
+esac
+`
+
+// TestJITHandler_Scan ...
+func TestJITHandler_Scan(t *testing.T) {
+	dir, e := ioutil.TempDir("", "jit")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "video.mp4")
+	_ = ioutil.WriteFile(file, []byte("video"), 0644)
+	gate := filepath.Join(dir, "gate")
+	_ = os.Setenv("JIT_GATE", gate)
+	defer os.Unsetenv("JIT_GATE")
+	defer fakeCommand(t, "ffprobe", fakeJITGatedProbe)()
+
+	h, e := NewJITHandler(file, OutputOption(filepath.Join(dir, "cache")), HLSTimeOption(2))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer h.Close()
+
+	//the first segment is playable before the scan ends and starts at 0
+	for i := 0; h.segments() < 1; i++ {
+		if i > 100 {
+			t.Fatal("no segment while scanning")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	p := h.Playlist()
+	if p.PlaylistType != "EVENT" || p.EndList || len(p.Segments) != 1 || p.Segments[0].Duration != 2085417*time.Microsecond {
+		t.Fatalf("%+v", p)
+	}
+	if w := httptest.NewRecorder(); true {
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/video/segment-00001.ts", nil))
+		if w.Code != 404 {
+			t.Fatal(w.Code)
+		}
+	}
+
+	_ = ioutil.WriteFile(gate, nil, 0644)
+	<-h.scanned
+	p = h.Playlist()
+	if p.PlaylistType != "EVENT" || !p.EndList || len(p.Segments) != 3 || p.Segments[2].Duration != 1912583*time.Microsecond {
+		t.Fatalf("%+v", p)
+	}
+}
+
+// TestJITHandler_Grid ...
+func TestJITHandler_Grid(t *testing.T) {
+	dir, e := ioutil.TempDir("", "jit")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "video.mp4")
+	_ = ioutil.WriteFile(file, []byte("video"), 0644)
+	defer fakeCommand(t, "ffprobe", fakeJITGatedProbe)()
+
+	//a re-encoded video does not wait for the keyframes
+	h, e := NewJITHandler(file, OutputOption(filepath.Join(dir, "cache")), HLSTimeOption(4), ScaleOption(720))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer h.Close()
+	p := h.Playlist()
+	if p.PlaylistType != "VOD" || !p.EndList || len(p.Segments) != 2 || p.Segments[1].Duration != 2*time.Second {
+		t.Fatalf("%+v", p)
+	}
+}
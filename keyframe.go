@@ -0,0 +1,214 @@
+package fftool
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const packetEntries = "packet=stream_index,pts_time,dts_time,duration_time,size,pos,flags"
+
+// Packet ...
+type Packet struct {
+	StreamIndex int64
+	PTS         time.Duration
+	DTS         time.Duration
+	Duration    time.Duration
+	Size        int64
+	Pos         int64 //byte offset in the file, -1 when unknown
+	Flags       string
+}
+
+// Keyframe ...
+func (p *Packet) Keyframe() bool {
+	return strings.Contains(p.Flags, "K")
+}
+
+// Keyframe ...
+type Keyframe struct {
+	Time time.Duration
+	Pos  int64
+	Size int64
+}
+
+// GOP ...
+type GOP struct {
+	Count int
+	Min   time.Duration
+	Max   time.Duration
+	Avg   time.Duration
+}
+
+// probeValue an ffprobe value printed either as a number or as a string
+type probeValue string
+
+// UnmarshalJSON ...
+func (v *probeValue) UnmarshalJSON(b []byte) error {
+	*v = probeValue(strings.Trim(string(b), `"`))
+	return nil
+}
+
+type probePacket struct {
+	StreamIndex  int64      `json:"stream_index"`
+	PTSTime      probeValue `json:"pts_time"`
+	DTSTime      probeValue `json:"dts_time"`
+	DurationTime probeValue `json:"duration_time"`
+	Size         probeValue `json:"size"`
+	Pos          probeValue `json:"pos"`
+	Flags        string     `json:"flags"`
+}
+
+func (p *probePacket) packet() Packet {
+	pkt := Packet{
+		StreamIndex: p.StreamIndex,
+		Pos:         -1,
+		Flags:       p.Flags,
+	}
+	pkt.PTS, _ = parseSeconds(string(p.PTSTime))
+	pkt.DTS, _ = parseSeconds(string(p.DTSTime))
+	pkt.Duration, _ = parseSeconds(string(p.DurationTime))
+	pkt.Size, _ = parseInt(string(p.Size))
+	if pos, e := parseInt(string(p.Pos)); e == nil {
+		pkt.Pos = pos
+	}
+	return pkt
+}
+
+// FFProbePackets stream the packets of the selected stream(ffprobe stream specifier like "V:0") to fn,
+// the output is parsed while ffprobe is running so long files are not buffered
+func FFProbePackets(file string, stream string, fn func(p Packet) error) error {
+	return ffprobePackets(file, stream, false, fn)
+}
+
+// FFProbePacketsSafe stream the packets with only the file protocol allowed
+func FFProbePacketsSafe(file string, stream string, fn func(p Packet) error) error {
+	return ffprobePackets(file, stream, true, fn)
+}
+
+func ffprobePackets(file string, stream string, safe bool, fn func(p Packet) error) error {
+	probe := NewFFProbe()
+	probe.SetArgs("-v error -print_format json -show_entries " + packetEntries)
+	if stream != "" {
+		probe.AddArgs("-select_streams")
+		probe.AddArgs(stream)
+	}
+	probe.Args = append(probe.Args, probeInput(file, safe)...)
+	return probe.RunReader(func(r io.Reader) error {
+		return decodeJSONArray(r, "packets", func(dec *json.Decoder) error {
+			var p probePacket
+			if e := dec.Decode(&p); e != nil {
+				return e
+			}
+			return fn(p.packet())
+		})
+	})
+}
+
+// FFProbeKeyframes returns the keyframes of the first video stream which is not a cover image
+func FFProbeKeyframes(file string) ([]Keyframe, error) {
+	return ffprobeKeyframes(file, false)
+}
+
+// FFProbeKeyframesSafe returns the keyframes with only the file protocol allowed
+func FFProbeKeyframesSafe(file string) ([]Keyframe, error) {
+	return ffprobeKeyframes(file, true)
+}
+
+func ffprobeKeyframes(file string, safe bool) ([]Keyframe, error) {
+	var keyframes []Keyframe
+	e := ffprobePackets(file, "V:0", safe, func(p Packet) error {
+		if p.Keyframe() {
+			keyframes = append(keyframes, Keyframe{Time: p.PTS, Pos: p.Pos, Size: p.Size})
+		}
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return keyframes, nil
+}
+
+// decodeJSONArray call fn for every element of the top level array named key
+func decodeJSONArray(r io.Reader, key string, fn func(dec *json.Decoder) error) error {
+	dec := json.NewDecoder(r)
+	depth := 0
+	for {
+		tok, e := dec.Token()
+		if e == io.EOF {
+			return nil
+		}
+		if e != nil {
+			return e
+		}
+		switch v := tok.(type) {
+		case json.Delim:
+			if v == '{' || v == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		case string:
+			if depth != 1 || v != key {
+				continue
+			}
+			tok, e = dec.Token()
+			if e != nil {
+				return e
+			}
+			if tok != json.Delim('[') {
+				return xerrors.Errorf("%s is not an array", key)
+			}
+			for dec.More() {
+				if e := fn(dec); e != nil {
+					return e
+				}
+			}
+			//the closing ']'
+			if _, e := dec.Token(); e != nil {
+				return e
+			}
+		}
+	}
+}
+
+// AnalyzeGOP report the keyframe intervals, the last gop ends at duration
+func AnalyzeGOP(keyframes []Keyframe, duration time.Duration) GOP {
+	gop := GOP{}
+	var sum time.Duration
+	for i, k := range keyframes {
+		end := duration
+		if i+1 < len(keyframes) {
+			end = keyframes[i+1].Time
+		}
+		d := end - k.Time
+		if d <= 0 {
+			continue
+		}
+		if gop.Count == 0 || d < gop.Min {
+			gop.Min = d
+		}
+		if d > gop.Max {
+			gop.Max = d
+		}
+		sum += d
+		gop.Count++
+	}
+	if gop.Count > 0 {
+		gop.Avg = sum / time.Duration(gop.Count)
+	}
+	return gop
+}
+
+// CutPoints choose keyframe times at least interval apart, a stream copy can only be cut on keyframes
+func CutPoints(keyframes []Keyframe, interval time.Duration) []time.Duration {
+	var points []time.Duration
+	for _, k := range keyframes {
+		if len(points) == 0 || k.Time-points[len(points)-1] >= interval {
+			points = append(points, k.Time)
+		}
+	}
+	return points
+}
@@ -0,0 +1,53 @@
+package fftool
+
+import (
+	"testing"
+	"time"
+)
+
+const testPacketsJSON = `{
+    "packets": [
+        {"stream_index": 0, "pts_time": "0.000000", "dts_time": "-0.083417", "duration_time": "0.041708", "size": "24529", "pos": "48", "flags": "K_"},
+        {"stream_index": 0, "pts_time": "0.166833", "dts_time": "-0.041708", "duration_time": "0.041708", "size": "1200", "pos": "24577", "flags": "__"},
+        {"stream_index": 0, "pts_time": "2.002000", "dts_time": "1.960292", "duration_time": "0.041708", "size": "20000", "pos": "90000", "flags": "K_"},
+        {"stream_index": 0, "pts_time": "4.004000", "dts_time": "3.962292", "duration_time": "0.041708", "size": "21000", "pos": "190000", "flags": "K__"}
+    ]
+}`
+
+// TestFFProbeKeyframes ...
+func TestFFProbeKeyframes(t *testing.T) {
+	defer fakeCommand(t, "ffprobe", "cat <<'EOF'\n"+testPacketsJSON+"\nEOF\n")()
+	keyframes, e := FFProbeKeyframes("video.mp4")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(keyframes) != 3 || keyframes[1].Time != 2002*time.Millisecond || keyframes[1].Pos != 90000 || keyframes[1].Size != 20000 {
+		t.Fatalf("%+v", keyframes)
+	}
+	gop := AnalyzeGOP(keyframes, 6*time.Second)
+	if gop.Count != 3 || gop.Min != 1996*time.Millisecond || gop.Max != 2002*time.Millisecond {
+		t.Fatalf("%+v", gop)
+	}
+	points := CutPoints(keyframes, 3*time.Second)
+	if len(points) != 2 || points[1] != 4004*time.Millisecond {
+		t.Fatal(points)
+	}
+}
+
+// TestFFProbePackets_Error ...
+func TestFFProbePackets_Error(t *testing.T) {
+	defer fakeCommand(t, "ffprobe", "echo 'no such file' >&2\nexit 1\n")()
+	if e := FFProbePackets("video.mp4", "", func(p Packet) error { return nil }); e == nil {
+		t.Fatal("want error")
+	}
+}
+
+// TestFFProbeKeyframesSafe ...
+func TestFFProbeKeyframesSafe(t *testing.T) {
+	defer fakeCommand(t, "ffprobe", `case "$*" in *"-protocol_whitelist file file:video.mp4") ;; *) exit 1 ;; esac
+cat <<'EOF'
+`+testPacketsJSON+"\nEOF\n")()
+	if keyframes, e := FFProbeKeyframesSafe("video.mp4"); e != nil || len(keyframes) != 3 {
+		t.Fatal(keyframes, e)
+	}
+}
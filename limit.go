@@ -0,0 +1,142 @@
+package fftool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const limitCheckInterval = 500 * time.Millisecond
+
+// LimitKind ...
+type LimitKind string
+
+// LimitTimeout ...
+const (
+	LimitTimeout LimitKind = "timeout"
+	LimitMemory  LimitKind = "memory"
+	LimitOutput  LimitKind = "output"
+)
+
+// Limits resource limits for a spawned process
+type Limits struct {
+	Nice           int           //cpu niceness(-20~19)
+	Threads        int           //-threads
+	FilterThreads  int           //-filter_threads
+	Timeout        time.Duration //wall clock timeout
+	MaxMemory      uint64        //max address space bytes (rlimit)
+	MaxOutputBytes int64         //max bytes written to the output path
+}
+
+// LimitError ...
+type LimitError struct {
+	Kind  LimitKind
+	Limit string
+}
+
+// Error ...
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("process exceeded %s limit(%s)", e.Kind, e.Limit)
+}
+
+func (l *Limits) args() (input string, output string) {
+	if l == nil {
+		return "", ""
+	}
+	if l.FilterThreads > 0 {
+		input = fmt.Sprintf("-filter_threads %d", l.FilterThreads)
+	}
+	if l.Threads > 0 {
+		output = fmt.Sprintf("-threads %d", l.Threads)
+	}
+	return input, output
+}
+
+// limitWatcher kill the process when a limit is exceeded
+type limitWatcher struct {
+	mu     sync.Mutex
+	limits *Limits
+	err    error
+	cancel func()
+}
+
+func (w *limitWatcher) exceed(kind LimitKind, limit string) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = &LimitError{Kind: kind, Limit: limit}
+	}
+	w.mu.Unlock()
+	w.cancel()
+}
+
+// Err ...
+func (w *limitWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// checkLine check the output line for out of memory errors
+func (w *limitWatcher) checkLine(line string) {
+	if w.limits.MaxMemory == 0 {
+		return
+	}
+	if strings.Contains(line, "Cannot allocate memory") || strings.Contains(line, "Out of memory") {
+		w.exceed(LimitMemory, fmt.Sprint(w.limits.MaxMemory))
+	}
+}
+
+// watchOutput poll the output path size until done is closed
+func (w *limitWatcher) watchOutput(path string, done <-chan struct{}) {
+	if w.limits.MaxOutputBytes <= 0 || path == "" {
+		return
+	}
+	t := time.NewTicker(limitCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			if size := pathSize(path); size > w.limits.MaxOutputBytes {
+				log.With("path", path, "size", size).Error("output limit")
+				w.exceed(LimitOutput, fmt.Sprint(w.limits.MaxOutputBytes))
+				return
+			}
+		}
+	}
+}
+
+// pathSize returns the size of a file or the sum of all files in a directory
+func pathSize(path string) (size int64) {
+	_ = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// check returns the limit error if the process in state was stopped by a limit
+func (w *limitWatcher) check(ctx context.Context, state *os.ProcessState) error {
+	if err := w.Err(); err != nil {
+		return err
+	}
+	if ctx.Err() == context.DeadlineExceeded && w.limits.Timeout > 0 {
+		return &LimitError{Kind: LimitTimeout, Limit: w.limits.Timeout.String()}
+	}
+	//the rlimit fails the allocations, which checkLine reports from the output, or the process
+	//dies by a signal when it does not check an allocation. A process killed by the context is not
+	if w.limits.MaxMemory > 0 && ctx.Err() == nil && signaled(state) {
+		return &LimitError{Kind: LimitMemory, Limit: fmt.Sprint(w.limits.MaxMemory)}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package fftool
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// signaled check whether the process was killed by a signal
+func signaled(state *os.ProcessState) bool {
+	if state == nil {
+		return false
+	}
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	return ok && ws.Signaled()
+}
+
+// limitCommand returns the command wrapped by a shell which sets the rlimit and niceness and then
+// execs the command, so the limits are in place before the command starts
+func limitCommand(name string, args []string, l *Limits) (string, []string) {
+	if l == nil || (l.Nice == 0 && l.MaxMemory == 0) {
+		return name, args
+	}
+	var script []string
+	if l.MaxMemory > 0 {
+		//ulimit -v counts KiB
+		kib := l.MaxMemory / 1024
+		if kib == 0 {
+			kib = 1
+		}
+		script = append(script, fmt.Sprintf("ulimit -v %d", kib))
+	}
+	if l.Nice != 0 {
+		script = append(script, fmt.Sprintf(`exec nice -n %d "$0" "$@"`, l.Nice))
+	} else {
+		script = append(script, `exec "$0" "$@"`)
+	}
+	return "/bin/sh", append([]string{"-c", strings.Join(script, " && "), name}, args...)
+}
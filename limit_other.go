@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package fftool
+
+import "os"
+
+// signaled the memory limit is not set on this platform, so a signal is not caused by it
+func signaled(state *os.ProcessState) bool {
+	return false
+}
+
+// limitCommand niceness and rlimit are only supported on linux
+func limitCommand(name string, args []string, l *Limits) (string, []string) {
+	if l != nil && (l.Nice != 0 || l.MaxMemory > 0) {
+		log.With("cmd", name).Warn("nice and memory limits are not supported on this platform")
+	}
+	return name, args
+}
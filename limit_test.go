@@ -0,0 +1,108 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// TestLimits_Timeout ...
+func TestLimits_Timeout(t *testing.T) {
+	ctx := FFmpegContext()
+	ctx.Add(1)
+	cmd := New("sleep")
+	cmd.SetArgs("5")
+	cmd.Limits = &Limits{Timeout: 200 * time.Millisecond}
+	e := cmd.RunContext(ctx, nil)
+	var le *LimitError
+	if !xerrors.As(e, &le) || le.Kind != LimitTimeout {
+		t.Fatalf("want timeout limit error, got %v", e)
+	}
+}
+
+// TestLimits_Args ...
+func TestLimits_Args(t *testing.T) {
+	input, output := (&Limits{Threads: 2, FilterThreads: 4}).args()
+	if input != "-filter_threads 4" || output != "-threads 2" {
+		t.Fatal(input, output)
+	}
+	var l *Limits
+	if input, output = l.args(); input != "" || output != "" {
+		t.Fatal(input, output)
+	}
+}
+
+// TestPathSize ...
+func TestPathSize(t *testing.T) {
+	dir, e := ioutil.TempDir("", "fftool")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	_ = ioutil.WriteFile(filepath.Join(dir, "a.ts"), make([]byte, 100), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "b.ts"), make([]byte, 50), 0644)
+	if size := pathSize(dir); size != 150 {
+		t.Fatal(size)
+	}
+}
+
+// TestLimits_Output ...
+func TestLimits_Output(t *testing.T) {
+	dir, e := ioutil.TempDir("", "fftool")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	ctx := FFmpegContext()
+	ctx.Add(1)
+	cmd := New("sh")
+	cmd.Args = []string{"-c", "while true; do echo 0123456789 >> " + filepath.Join(dir, "out") + "; done"}
+	cmd.OutPath = dir
+	cmd.Limits = &Limits{MaxOutputBytes: 1024, Timeout: 10 * time.Second}
+	e = cmd.RunContext(ctx, nil)
+	var le *LimitError
+	if !xerrors.As(e, &le) || le.Kind != LimitOutput {
+		t.Fatalf("want output limit error, got %v", e)
+	}
+}
+
+// TestLimits_Memory ...
+func TestLimits_Memory(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("memory limit is only supported on linux")
+	}
+	ctx := FFmpegContext()
+	ctx.Add(2)
+	info := make(chan string, 16)
+	cmd := New("sh")
+	cmd.Args = []string{"-c", "ulimit -v"}
+	cmd.Limits = &Limits{MaxMemory: 1 << 30}
+	if e := cmd.RunContext(ctx, info); e != nil {
+		t.Fatal(e)
+	}
+	//the limit is set before the command starts
+	if v := <-info; v != "1048576" {
+		t.Fatal(v)
+	}
+
+	//a process under the memory limit killed by a signal is reported as the memory limit
+	cmd.Args = []string{"-c", "kill -SEGV $$"}
+	e := cmd.RunContext(ctx, nil)
+	var le *LimitError
+	if !xerrors.As(e, &le) || le.Kind != LimitMemory {
+		t.Fatalf("want memory limit error, got %v", e)
+	}
+
+	//without a memory limit the crash keeps the exit error
+	ctx.Add(1)
+	cmd.Limits = nil
+	e = cmd.RunContext(ctx, nil)
+	if e == nil || xerrors.As(e, &le) {
+		t.Fatalf("want exit error, got %v", e)
+	}
+}
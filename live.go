@@ -0,0 +1,303 @@
+package fftool
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+	"golang.org/x/xerrors"
+)
+
+// ErrLiveInput ...
+var ErrLiveInput = xerrors.New("live input must be a rtmp, srt or udp url")
+
+// LiveArgs ...
+type LiveArgs struct {
+	Input           string
+	Output          string
+	M3U8            string
+	SegmentFileName string
+	HLSTime         int
+	ListSize        int  //segments in the sliding window, 0 keeps all
+	Event           bool //EVENT playlist for DVR, the segments are never deleted
+	Video           string
+	Audio           string
+	InputTimeout    time.Duration //the input is dropped when no data is read for InputTimeout
+	MaxRestarts     int           //restarts after an input drop, -1 is unlimited
+	RestartDelay    time.Duration
+	PartTarget      time.Duration //duration of the low-latency parts, 0 disables low latency
+	Limits          *Limits
+}
+
+// LiveOptions ...
+type LiveOptions func(args *LiveArgs)
+
+// LiveOutputOption ...
+func LiveOutputOption(dir string) LiveOptions {
+	return func(args *LiveArgs) {
+		args.Output = dir
+	}
+}
+
+// LiveWindowOption keep the last n segments in the playlist and delete the older segments
+func LiveWindowOption(n int) LiveOptions {
+	return func(args *LiveArgs) {
+		args.ListSize = n
+		args.Event = false
+	}
+}
+
+// LiveEventOption write an EVENT playlist which keeps every segment for DVR
+func LiveEventOption() LiveOptions {
+	return func(args *LiveArgs) {
+		args.ListSize = 0
+		args.Event = true
+	}
+}
+
+// LiveHLSTimeOption ...
+func LiveHLSTimeOption(i int) LiveOptions {
+	return func(args *LiveArgs) {
+		args.HLSTime = i
+	}
+}
+
+// LiveCodecOption set the video and the audio codec, "copy" keeps the input
+func LiveCodecOption(video, audio string) LiveOptions {
+	return func(args *LiveArgs) {
+		args.Video = video
+		args.Audio = audio
+	}
+}
+
+// LiveRestartOption restart ffmpeg at most n times after an input drop, -1 is unlimited
+func LiveRestartOption(n int, delay time.Duration) LiveOptions {
+	return func(args *LiveArgs) {
+		args.MaxRestarts = n
+		args.RestartDelay = delay
+	}
+}
+
+// LiveInputTimeoutOption ...
+func LiveInputTimeoutOption(d time.Duration) LiveOptions {
+	return func(args *LiveArgs) {
+		args.InputTimeout = d
+	}
+}
+
+// LiveLimitsOption ...
+func LiveLimitsOption(l Limits) LiveOptions {
+	return func(args *LiveArgs) {
+		args.Limits = &l
+	}
+}
+
+// Live a running live ingest
+type Live struct {
+	Args     *LiveArgs
+	ctx      Context
+	done     chan struct{}
+	mu       sync.Mutex
+	restarts int
+	e        error
+	parts    *partPackager //packager of the low-latency playlist
+}
+
+// FFMpegLive ingest the network input, ffmpeg listens on rtmp:// and srt:// (mode=listener is added)
+// and reads udp://, the rolling playlist is written to the output until Stop
+func FFMpegLive(ctx Context, input string, opts ...LiveOptions) (*Live, error) {
+	la := &LiveArgs{
+		Input:           input,
+		Output:          ".",
+		M3U8:            "media.m3u8",
+		SegmentFileName: "media-%05d.ts",
+		HLSTime:         4,
+		ListSize:        6,
+		Video:           "copy",
+		Audio:           "aac",
+		InputTimeout:    10 * time.Second,
+		MaxRestarts:     -1,
+		RestartDelay:    time.Second,
+	}
+	for _, o := range opts {
+		o(la)
+	}
+	if e := la.checkInput(); e != nil {
+		return nil, e
+	}
+	var e error
+	if la.Output, e = filepath.Abs(la.Output); e != nil {
+		return nil, e
+	}
+	if e := os.MkdirAll(la.Output, os.ModePerm); e != nil {
+		return nil, e
+	}
+	l := &Live{Args: la, ctx: childContext(ctx), done: make(chan struct{})}
+	if la.PartTarget > 0 {
+		l.parts = newPartPackager(la)
+	}
+	go l.run()
+	return l, nil
+}
+
+// checkInput accept the rtmp, srt and udp inputs
+func (la *LiveArgs) checkInput() error {
+	u, e := url.Parse(la.Input)
+	if e != nil {
+		return xerrors.Errorf("%s: %w", e.Error(), ErrLiveInput)
+	}
+	switch u.Scheme {
+	case "rtmp", "udp":
+	case "srt":
+		q := u.Query()
+		if q.Get("mode") == "" {
+			q.Set("mode", "listener")
+			u.RawQuery = q.Encode()
+			la.Input = u.String()
+		}
+	default:
+		return ErrLiveInput
+	}
+	return nil
+}
+
+// args returns the ffmpeg arguments, a restart appends to the playlist after a discontinuity
+func (la *LiveArgs) args(restart bool) []string {
+	args := []string{"-y"}
+	in, out := la.Limits.args()
+	args = append(args, strings.Fields(in)...)
+	if la.InputTimeout > 0 {
+		args = append(args, "-rw_timeout", fmt.Sprint(int64(la.InputTimeout/time.Microsecond)))
+	}
+	if strings.HasPrefix(la.Input, "rtmp:") {
+		args = append(args, "-listen", "1")
+	}
+	args = append(args, "-i", la.Input, "-map", "0:v:0?", "-map", "0:a:0?", "-c:v", la.Video, "-c:a", la.Audio)
+	if la.Video != "copy" {
+		//cut the segments on time
+		args = append(args, "-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", la.HLSTime))
+	}
+	args = append(args, strings.Fields(out)...)
+	if la.PartTarget > 0 {
+		return append(args, la.partArgs(restart)...)
+	}
+	flags := []string{"omit_endlist", "program_date_time"}
+	if !la.Event {
+		flags = append(flags, "delete_segments")
+	}
+	if restart {
+		flags = append(flags, "append_list", "discont_start")
+	}
+	args = append(args, "-f", "hls", "-hls_time", fmt.Sprint(la.HLSTime), "-hls_list_size", fmt.Sprint(la.ListSize),
+		"-hls_flags", strings.Join(flags, "+"))
+	if la.Event {
+		args = append(args, "-hls_playlist_type", "event")
+	}
+	return append(args, "-hls_segment_filename", filepath.Join(la.Output, la.SegmentFileName), filepath.Join(la.Output, la.M3U8))
+}
+
+// run ingest and package the low-latency playlist until the ingest ended
+func (l *Live) run() {
+	defer close(l.done)
+	if l.parts == nil {
+		l.ingest()
+		return
+	}
+	stop := make(chan struct{})
+	packaged := make(chan struct{})
+	go func() {
+		defer close(packaged)
+		l.parts.run(stop)
+	}()
+	l.ingest()
+	close(stop)
+	<-packaged
+}
+
+// ingest run ffmpeg until the context is canceled or the restarts are exhausted, ffmpegRun returns
+// after the process exited so the playlist is complete when ingest returns
+func (l *Live) ingest() {
+	la := l.Args
+	for {
+		_, e := os.Stat(filepath.Join(la.Output, la.playlist()))
+		ffmpeg := NewFFMpeg()
+		//append after a drop or to the playlist of a previous ingest
+		ffmpeg.Args = la.args(e == nil)
+		ffmpeg.Limits = la.Limits
+		ffmpeg.Interrupt = true
+		e = ffmpegRun(l.ctx, ffmpeg)
+		if l.ctx.Context().Err() != nil {
+			return
+		}
+		l.mu.Lock()
+		if la.MaxRestarts >= 0 && l.restarts >= la.MaxRestarts {
+			if e == nil {
+				e = xerrors.New("live input dropped")
+			}
+			l.e = e
+			l.mu.Unlock()
+			return
+		}
+		l.restarts++
+		l.mu.Unlock()
+		log.With("input", la.Input, "restarts", l.Restarts()).Warn("live input dropped, restart", e)
+		select {
+		case <-time.After(la.RestartDelay):
+		case <-l.ctx.Context().Done():
+			return
+		}
+	}
+}
+
+// Restarts returns the restarts after an input drop
+func (l *Live) Restarts() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.restarts
+}
+
+// Done is closed when the ingest ended
+func (l *Live) Done() <-chan struct{} {
+	return l.done
+}
+
+// Wait wait for the ingest and finish the playlist
+func (l *Live) Wait() error {
+	<-l.done
+	if e := l.finish(); e != nil {
+		return e
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.e
+}
+
+// Stop interrupt ffmpeg so it writes the last segment, then finish the playlist with EXT-X-ENDLIST,
+// the context passed to FFMpegLive is not canceled
+func (l *Live) Stop() error {
+	l.ctx.Cancel()
+	return l.Wait()
+}
+
+// finish add EXT-X-ENDLIST to the playlist
+func (l *Live) finish() error {
+	path := filepath.Join(l.Args.Output, l.Args.M3U8)
+	p, e := m3u8.ReadFile(path)
+	if os.IsNotExist(e) {
+		return nil
+	}
+	if e != nil {
+		return e
+	}
+	media, ok := p.(*m3u8.MediaPlaylist)
+	if !ok || media.EndList {
+		return nil
+	}
+	media.EndList = true
+	return m3u8.WriteFile(path, media)
+}
@@ -0,0 +1,135 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFFMpegLive_Restart ...
+func TestFFMpegLive_Restart(t *testing.T) {
+	dir, logFile, cleanup := testLogDir(t, "live")
+	defer cleanup()
+	defer fakeCommand(t, "ffmpeg", fakePlaylistFFmpeg)()
+
+	if _, e := FFMpegLive(nil, "http://example.com/live"); e != ErrLiveInput {
+		t.Fatal(e)
+	}
+	out := filepath.Join(dir, "out")
+	live, e := FFMpegLive(nil, "srt://127.0.0.1:9000", LiveOutputOption(out), LiveEventOption(), LiveRestartOption(2, 10*time.Millisecond))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if live.Args.Input != "srt://127.0.0.1:9000?mode=listener" {
+		t.Fatal(live.Args.Input)
+	}
+	if e := live.Wait(); e == nil || live.Restarts() != 2 {
+		t.Fatal(e, live.Restarts())
+	}
+	b, _ := ioutil.ReadFile(logFile)
+	runs := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(runs) != 3 {
+		t.Fatal(runs)
+	}
+	if !strings.Contains(runs[0], "-hls_flags omit_endlist+program_date_time -hls_playlist_type event") {
+		t.Fatal(runs[0])
+	}
+	if !strings.Contains(runs[1], "-hls_flags omit_endlist+program_date_time+append_list+discont_start") {
+		t.Fatal(runs[1])
+	}
+	list, _ := ioutil.ReadFile(filepath.Join(out, "media.m3u8"))
+	if !strings.HasSuffix(string(list), "#EXT-X-ENDLIST\n") {
+		t.Fatal(string(list))
+	}
+}
+
+// fakeInterruptFFmpeg write a playlist to the last argument and the last segment on SIGINT
+const fakeInterruptFFmpeg = `for out; do :; done
+trap 'sleep 0.2; printf "#EXTINF:1.000000,\nmedia-00001.ts\n" >> "$out"; exit 255' INT
+printf '#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000+0000\n#EXTINF:2.000000,\nmedia-00000.ts\n' > "$out"
+while :; do sleep 0.05; done
+`
+
+// TestFFMpegLive_Stop ...
+func TestFFMpegLive_Stop(t *testing.T) {
+	dir, e := ioutil.TempDir("", "live")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	defer fakeCommand(t, "ffmpeg", fakeInterruptFFmpeg)()
+
+	ctx := FFmpegContext()
+	live, e := FFMpegLive(ctx, "udp://127.0.0.1:9000", LiveOutputOption(dir), LiveEventOption())
+	if e != nil {
+		t.Fatal(e)
+	}
+	path := filepath.Join(dir, "media.m3u8")
+	for i := 0; ; i++ {
+		if _, e := os.Stat(path); e == nil {
+			break
+		}
+		if i > 100 {
+			t.Fatal("no playlist")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if e := live.Stop(); e != nil {
+		t.Fatal(e)
+	}
+	if ctx.Context().Err() != nil {
+		t.Fatal("the context of the caller is canceled")
+	}
+	list, _ := ioutil.ReadFile(path)
+	if !strings.HasSuffix(string(list), "media-00001.ts\n#EXT-X-ENDLIST\n") {
+		t.Fatal(string(list))
+	}
+}
+
+// TestLiveArgs_Args ...
+func TestLiveArgs_Args(t *testing.T) {
+	la := &LiveArgs{Input: "rtmp://127.0.0.1:1935/live/test", Output: "/out", M3U8: "media.m3u8", SegmentFileName: "media-%05d.ts",
+		HLSTime: 2, ListSize: 5, Video: "libx264", Audio: "aac", InputTimeout: 3 * time.Second}
+	args := strings.Join(la.args(false), " ")
+	for _, want := range []string{
+		"-rw_timeout 3000000 -listen 1 -i rtmp://127.0.0.1:1935/live/test",
+		"-force_key_frames expr:gte(t,n_forced*2)",
+		"-hls_list_size 5 -hls_flags omit_endlist+program_date_time+delete_segments -hls_segment_filename /out/media-%05d.ts /out/media.m3u8",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("missing %q in %s", want, args)
+		}
+	}
+}
+
+// TestFFMpegLive_Lavfi feed a local udp input from a lavfi generated stream, it needs ffmpeg
+func TestFFMpegLive_Lavfi(t *testing.T) {
+	if _, e := exec.LookPath("ffmpeg"); e != nil {
+		t.Skip("ffmpeg not found")
+	}
+	dir, e := ioutil.TempDir("", "live")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	live, e := FFMpegLive(nil, "udp://127.0.0.1:23456", LiveOutputOption(dir), LiveHLSTimeOption(1), LiveWindowOption(3),
+		LiveCodecOption("libx264", "aac"), LiveRestartOption(0, 0))
+	if e != nil {
+		t.Fatal(e)
+	}
+	feed := exec.Command("ffmpeg", "-re", "-f", "lavfi", "-i", "testsrc=size=320x240:rate=25", "-f", "lavfi", "-i", "sine",
+		"-t", "5", "-c:v", "libx264", "-g", "25", "-c:a", "aac", "-f", "mpegts", "udp://127.0.0.1:23456")
+	if e := feed.Run(); e != nil {
+		t.Fatal(e)
+	}
+	time.Sleep(2 * time.Second)
+	_ = live.Stop()
+	r, e := ValidateHLS(dir, "media.m3u8", ValidateSampleOption(-1))
+	if e != nil || len(r.Issues) != 0 || r.Segments == 0 || r.Segments > 3 {
+		t.Fatal(r, e)
+	}
+}
@@ -0,0 +1,391 @@
+package fftool
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+)
+
+const (
+	llPartPlaylist     = ".parts.m3u8"
+	llPartFileName     = "part-%05d.ts"
+	llPartSegments     = 3 //segments listed with their parts
+	llBlockingSegments = 2 //a blocking reload can wait for at most this many segments ahead
+)
+
+// LiveLowLatencyOption write a low-latency HLS playlist with parts of part duration,
+// every part is independent only when it starts a segment so the video should be re-encoded
+func LiveLowLatencyOption(part time.Duration) LiveOptions {
+	return func(args *LiveArgs) {
+		args.PartTarget = part
+	}
+}
+
+// partsPerSegment returns the parts concatenated to a segment
+func (la *LiveArgs) partsPerSegment() int {
+	n := int(math.Round(float64(time.Duration(la.HLSTime)*time.Second) / float64(la.PartTarget)))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// playlist returns the playlist written by ffmpeg, the parts playlist with low latency
+func (la *LiveArgs) playlist() string {
+	if la.PartTarget > 0 {
+		return llPartPlaylist
+	}
+	return la.M3U8
+}
+
+// partArgs returns the hls muxer arguments of the parts, ffmpeg writes the parts as the segments
+// of a hidden playlist which is packaged into the low-latency playlist
+func (la *LiveArgs) partArgs(restart bool) []string {
+	flags := []string{"omit_endlist", "delete_segments", "split_by_time", "temp_file"}
+	if restart {
+		flags = append(flags, "append_list", "discont_start")
+	}
+	return []string{"-f", "hls", "-hls_time", strconv.FormatFloat(la.PartTarget.Seconds(), 'f', -1, 64),
+		"-hls_list_size", fmt.Sprint(la.partsPerSegment() * (llPartSegments + 1)),
+		"-hls_flags", strings.Join(flags, "+"),
+		"-hls_segment_filename", filepath.Join(la.Output, llPartFileName), filepath.Join(la.Output, llPartPlaylist)}
+}
+
+// llSegment a segment of the low-latency playlist, it is complete when its file is written
+type llSegment struct {
+	seq           int64
+	parts         []*m3u8.Part
+	files         []string
+	discontinuity bool
+}
+
+// duration ...
+func (s *llSegment) duration() (d time.Duration) {
+	for _, p := range s.parts {
+		d += p.Duration
+	}
+	return d
+}
+
+// partPackager poll the parts playlist of ffmpeg and write the low-latency playlist
+type partPackager struct {
+	la       *LiveArgs
+	mu       sync.Mutex
+	changed  chan struct{} //closed and replaced on every update
+	next     int64         //the absolute index of the next part
+	seq      int64         //the sequence of the current segment
+	current  *llSegment
+	segments []*llSegment
+	playlist *m3u8.MediaPlaylist
+	ended    bool
+}
+
+func newPartPackager(la *LiveArgs) *partPackager {
+	return &partPackager{
+		la:      la,
+		changed: make(chan struct{}),
+		current: &llSegment{},
+	}
+}
+
+// run poll until stop is closed, the pending parts are written as the last segment at the end
+func (pk *partPackager) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(pk.la.PartTarget / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if e := pk.poll(); e != nil && !os.IsNotExist(e) {
+				log.With("playlist", llPartPlaylist).Error(e)
+			}
+		case <-stop:
+			if e := pk.poll(); e != nil && !os.IsNotExist(e) {
+				log.With("playlist", llPartPlaylist).Error(e)
+			}
+			if e := pk.end(); e != nil {
+				log.With("playlist", pk.la.M3U8).Error(e)
+			}
+			return
+		}
+	}
+}
+
+// poll add the new parts of the parts playlist
+func (pk *partPackager) poll() error {
+	p, e := m3u8.ReadFile(filepath.Join(pk.la.Output, llPartPlaylist))
+	if e != nil {
+		return e
+	}
+	media, ok := p.(*m3u8.MediaPlaylist)
+	if !ok {
+		return nil
+	}
+	pk.mu.Lock()
+	defer pk.mu.Unlock()
+	added := false
+	for i, s := range media.Segments {
+		index := media.MediaSequence + int64(i)
+		if index < pk.next {
+			continue
+		}
+		if s.Discontinuity && len(pk.current.parts) > 0 {
+			if e := pk.complete(); e != nil {
+				return e
+			}
+		}
+		pk.current.discontinuity = pk.current.discontinuity || s.Discontinuity
+		pk.current.parts = append(pk.current.parts, &m3u8.Part{
+			URI:         path.Base(s.URI),
+			Duration:    s.Duration,
+			Independent: len(pk.current.parts) == 0,
+		})
+		pk.current.files = append(pk.current.files, filepath.Join(pk.la.Output, filepath.Base(s.URI)))
+		pk.next = index + 1
+		added = true
+		if len(pk.current.parts) >= pk.la.partsPerSegment() {
+			if e := pk.complete(); e != nil {
+				return e
+			}
+		}
+	}
+	if !added {
+		return nil
+	}
+	return pk.write()
+}
+
+// complete concatenate the parts of the current segment to the segment file and slide the window
+func (pk *partPackager) complete() error {
+	s := pk.current
+	s.seq = pk.seq
+	if e := concatFiles(pk.segmentFile(s.seq), s.files); e != nil {
+		return e
+	}
+	pk.segments = append(pk.segments, s)
+	pk.seq++
+	pk.current = &llSegment{}
+	if pk.la.Event || pk.la.ListSize <= 0 {
+		return nil
+	}
+	for len(pk.segments) > pk.la.ListSize {
+		_ = os.Remove(pk.segmentFile(pk.segments[0].seq))
+		pk.segments = pk.segments[1:]
+	}
+	return nil
+}
+
+// end complete the pending parts and finish the playlist with EXT-X-ENDLIST
+func (pk *partPackager) end() error {
+	pk.mu.Lock()
+	defer pk.mu.Unlock()
+	if len(pk.current.parts) > 0 {
+		if e := pk.complete(); e != nil {
+			return e
+		}
+	}
+	pk.ended = true
+	return pk.write()
+}
+
+func (pk *partPackager) segmentFile(seq int64) string {
+	return filepath.Join(pk.la.Output, fmt.Sprintf(pk.la.SegmentFileName, seq))
+}
+
+// write write the low-latency playlist and wake up the blocking requests
+func (pk *partPackager) write() error {
+	la := pk.la
+	p := &m3u8.MediaPlaylist{
+		Version:        6,
+		TargetDuration: la.HLSTime,
+		MediaSequence:  pk.seq - int64(len(pk.segments)),
+		ServerControl:  &m3u8.ServerControl{CanBlockReload: true, PartHoldBack: 3 * la.PartTarget},
+		PartTarget:     la.PartTarget,
+		EndList:        pk.ended,
+	}
+	if la.Event {
+		p.PlaylistType = "EVENT"
+	}
+	for i, s := range pk.segments {
+		seg := &m3u8.Segment{
+			URI:           path.Base(filepath.ToSlash(pk.segmentFile(s.seq))),
+			Duration:      s.duration(),
+			Discontinuity: s.discontinuity,
+		}
+		if i >= len(pk.segments)-llPartSegments && !pk.ended {
+			seg.Parts = s.parts
+		}
+		p.Segments = append(p.Segments, seg)
+	}
+	if max := p.MaxDuration(); max > p.TargetDuration {
+		p.TargetDuration = max
+	}
+	if !pk.ended {
+		p.Parts = pk.current.parts
+		p.PreloadHint = &m3u8.PreloadHint{Type: "PART", URI: fmt.Sprintf(llPartFileName, pk.next)}
+	}
+	if e := m3u8.WriteFile(filepath.Join(la.Output, la.M3U8), p); e != nil {
+		return e
+	}
+	pk.playlist = p
+	close(pk.changed)
+	pk.changed = make(chan struct{})
+	return nil
+}
+
+// ready reports whether the playlist has part of segment msn, part < 0 waits for the whole segment,
+// bad is set when msn is too far ahead
+func (pk *partPackager) ready(msn int64, part int) (ok bool, bad bool) {
+	if pk.ended || msn < pk.seq {
+		return true, false
+	}
+	if msn > pk.seq+llBlockingSegments {
+		return false, true
+	}
+	return msn == pk.seq && part >= 0 && part < len(pk.current.parts), false
+}
+
+// wait wait until cond holds or timeout, cond is called with the lock held
+func (pk *partPackager) wait(r *http.Request, timeout time.Duration, cond func() bool) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		pk.mu.Lock()
+		ok := cond()
+		changed := pk.changed
+		pk.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		case <-r.Context().Done():
+			return false
+		}
+	}
+}
+
+// servePlaylist serve the playlist, a request with _HLS_msn and _HLS_part blocks until the playlist has that part
+func (pk *partPackager) servePlaylist(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	msn, part := int64(-1), -1
+	var e error
+	if v := q.Get("_HLS_msn"); v != "" {
+		if msn, e = strconv.ParseInt(v, 10, 64); e != nil || msn < 0 {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("_HLS_part"); v != "" {
+		if part, e = strconv.Atoi(v); e != nil || part < 0 || msn < 0 {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+	bad := false
+	ok := pk.wait(r, 3*time.Duration(pk.la.HLSTime)*time.Second, func() bool {
+		if msn < 0 {
+			return pk.playlist != nil
+		}
+		var ready bool
+		ready, bad = pk.ready(msn, part)
+		return ready && pk.playlist != nil || bad
+	})
+	if bad {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	var buf bytes.Buffer
+	pk.mu.Lock()
+	e = pk.playlist.Encode(&buf)
+	pk.mu.Unlock()
+	if e != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// waitHint hold the request of the preload hinted part until it is listed
+func (pk *partPackager) waitHint(r *http.Request, name string) bool {
+	return pk.wait(r, 3*pk.la.PartTarget+time.Second, func() bool {
+		return pk.ended || pk.playlist == nil || pk.playlist.PreloadHint == nil || pk.playlist.PreloadHint.URI != name
+	})
+}
+
+// Handler serve the output directory, with low latency the playlist supports blocking reload
+// and the request of the preload hinted part is held until the part is written
+func (l *Live) Handler() http.Handler {
+	files := http.FileServer(http.Dir(l.Args.Output))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Base(path.Clean("/" + r.URL.Path))
+		if pk := l.parts; pk != nil {
+			if name == l.Args.M3U8 {
+				pk.servePlaylist(w, r)
+				return
+			}
+			if name == llPartPlaylist {
+				http.NotFound(w, r)
+				return
+			}
+			if !pk.waitHint(r, name) {
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		if strings.EqualFold(path.Ext(name), ".m3u8") {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// concatFiles write the concatenation of files to out through a temporary file
+func concatFiles(out string, files []string) error {
+	tmp := out + ".tmp"
+	f, e := os.Create(tmp)
+	if e != nil {
+		return e
+	}
+	for _, file := range files {
+		if e = appendFile(f, file); e != nil {
+			break
+		}
+	}
+	if err := f.Close(); e == nil {
+		e = err
+	}
+	if e != nil {
+		_ = os.Remove(tmp)
+		return e
+	}
+	return os.Rename(tmp, out)
+}
+
+func appendFile(w io.Writer, file string) error {
+	f, e := os.Open(file)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+	_, e = io.Copy(w, f)
+	return e
+}
@@ -0,0 +1,132 @@
+package fftool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeParts write the parts first..last and the parts playlist listing them
+func writeParts(t *testing.T, dir string, first int, last int) {
+	t.Helper()
+	list := fmt.Sprintf("#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:%d\n", first)
+	for i := first; i <= last; i++ {
+		name := fmt.Sprintf(llPartFileName, i)
+		if e := ioutil.WriteFile(filepath.Join(dir, name), []byte(fmt.Sprintf("p%d,", i)), 0644); e != nil {
+			t.Fatal(e)
+		}
+		list += "#EXTINF:0.500000,\n" + name + "\n"
+	}
+	if e := ioutil.WriteFile(filepath.Join(dir, llPartPlaylist), []byte(list), 0644); e != nil {
+		t.Fatal(e)
+	}
+}
+
+// TestLiveArgs_PartArgs ...
+func TestLiveArgs_PartArgs(t *testing.T) {
+	la := &LiveArgs{Input: "udp://127.0.0.1:1234", Output: "/out", M3U8: "media.m3u8", SegmentFileName: "media-%05d.ts",
+		HLSTime: 2, ListSize: 5, Video: "libx264", Audio: "aac", PartTarget: 500 * time.Millisecond}
+	args := strings.Join(la.args(true), " ")
+	want := "-force_key_frames expr:gte(t,n_forced*2) -f hls -hls_time 0.5 -hls_list_size 16 " +
+		"-hls_flags omit_endlist+delete_segments+split_by_time+temp_file+append_list+discont_start " +
+		"-hls_segment_filename /out/part-%05d.ts /out/.parts.m3u8"
+	if !strings.HasSuffix(args, want) {
+		t.Fatalf("want suffix %q in %s", want, args)
+	}
+	if la.playlist() != llPartPlaylist {
+		t.Fatal(la.playlist())
+	}
+}
+
+// TestPartPackager ...
+func TestPartPackager(t *testing.T) {
+	dir, e := ioutil.TempDir("", "llhls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	la := &LiveArgs{Output: dir, M3U8: "media.m3u8", SegmentFileName: "media-%05d.ts", HLSTime: 1, ListSize: 2,
+		PartTarget: 500 * time.Millisecond}
+	pk := newPartPackager(la)
+	l := &Live{Args: la, parts: pk}
+	h := l.Handler()
+
+	writeParts(t, dir, 0, 2)
+	if e := pk.poll(); e != nil {
+		t.Fatal(e)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/media.m3u8", nil))
+	for _, want := range []string{
+		"#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=1.5\n#EXT-X-PART-INF:PART-TARGET=0.5\n",
+		"#EXT-X-PART:DURATION=0.5,URI=\"part-00000.ts\",INDEPENDENT=YES\n#EXT-X-PART:DURATION=0.5,URI=\"part-00001.ts\"\n#EXTINF:1.000000,\nmedia-00000.ts\n",
+		"#EXT-X-PART:DURATION=0.5,URI=\"part-00002.ts\",INDEPENDENT=YES\n#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"part-00003.ts\"\n",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("missing %q in\n%s", want, w.Body.String())
+		}
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "media-00000.ts")); string(b) != "p0,p1," {
+		t.Fatal(string(b))
+	}
+
+	//blocking reload of the next part and the preload hinted part
+	reload := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/media.m3u8?_HLS_msn=1&_HLS_part=1", nil))
+		reload <- w
+	}()
+	hint := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/part-00003.ts", nil))
+		hint <- w
+	}()
+	select {
+	case <-reload:
+		t.Fatal("reload did not block")
+	case <-hint:
+		t.Fatal("hinted part did not block")
+	case <-time.After(100 * time.Millisecond):
+	}
+	writeParts(t, dir, 1, 3)
+	if e := pk.poll(); e != nil {
+		t.Fatal(e)
+	}
+	if w := <-reload; w.Code != 200 || !strings.Contains(w.Body.String(), "media-00001.ts\n#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"part-00004.ts\"\n") {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	if w := <-hint; w.Code != 200 || w.Body.String() != "p3," {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/media.m3u8?_HLS_msn=9&_HLS_part=0", nil))
+	if w.Code != 400 {
+		t.Fatal(w.Code)
+	}
+
+	//the window slides and the pending part is the last segment at the end
+	writeParts(t, dir, 3, 6)
+	if e := pk.poll(); e != nil {
+		t.Fatal(e)
+	}
+	if e := pk.end(); e != nil {
+		t.Fatal(e)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "media.m3u8"))
+	list := string(b)
+	if !strings.Contains(list, "#EXT-X-MEDIA-SEQUENCE:2\n") || strings.Contains(list, "#EXT-X-PART:") ||
+		!strings.HasSuffix(list, "#EXTINF:0.500000,\nmedia-00003.ts\n#EXT-X-ENDLIST\n") {
+		t.Fatal(list)
+	}
+	if _, e := os.Stat(filepath.Join(dir, "media-00001.ts")); !os.IsNotExist(e) {
+		t.Fatal("segment out of the window kept", e)
+	}
+}
@@ -0,0 +1,59 @@
+package m3u8
+
+import (
+	"strings"
+)
+
+// parseAttributes parse an attribute list like `TYPE=AUDIO,NAME="a,b"`, the quotes are removed
+func parseAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.IndexByte(s, ','); comma >= 0 {
+			value, s = s[:comma], s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		attrs[name] = strings.TrimSpace(value)
+		s = strings.TrimPrefix(strings.TrimSpace(s), ",")
+	}
+	return attrs
+}
+
+// attributes an ordered attribute list, empty values are skipped
+type attributes struct {
+	list []string
+}
+
+// enum add an unquoted value
+func (a *attributes) enum(name, value string) {
+	if value != "" {
+		a.list = append(a.list, name+"="+value)
+	}
+}
+
+// quoted add a quoted string value, a quoted string cannot contain quotes or line breaks
+func (a *attributes) quoted(name, value string) {
+	if value != "" {
+		value = strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(value)
+		a.list = append(a.list, name+`="`+value+`"`)
+	}
+}
+
+// String ...
+func (a *attributes) String() string {
+	return strings.Join(a.list, ",")
+}
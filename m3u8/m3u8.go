@@ -0,0 +1,733 @@
+// Package m3u8 parse and write HLS media and master playlists
+package m3u8
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrNotPlaylist the input does not start with #EXTM3U
+var ErrNotPlaylist = xerrors.New("not a m3u8 playlist")
+
+// Playlist a *MediaPlaylist or a *MasterPlaylist
+type Playlist interface {
+	Encode(w io.Writer) error
+}
+
+// ByteRange a sub-range of the resource, Offset is -1 when the range follows the previous one
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// Key EXT-X-KEY, it applies to the segment and every following segment
+type Key struct {
+	Method            string
+	URI               string
+	IV                string
+	KeyFormat         string
+	KeyFormatVersions string
+}
+
+// Map EXT-X-MAP, the media initialization section
+type Map struct {
+	URI       string
+	ByteRange *ByteRange
+}
+
+// Part EXT-X-PART, a partial segment of low-latency HLS
+type Part struct {
+	URI         string
+	Duration    time.Duration
+	Independent bool
+	ByteRange   *ByteRange
+	Gap         bool
+}
+
+// PreloadHint EXT-X-PRELOAD-HINT, the resource the client can request before it is available
+type PreloadHint struct {
+	Type string //PART or MAP
+	URI  string
+}
+
+// ServerControl EXT-X-SERVER-CONTROL
+type ServerControl struct {
+	CanBlockReload bool
+	CanSkipUntil   time.Duration
+	HoldBack       time.Duration
+	PartHoldBack   time.Duration
+}
+
+// Segment a media segment with the tags before its URI
+type Segment struct {
+	URI             string
+	Duration        time.Duration
+	Title           string
+	ByteRange       *ByteRange
+	Discontinuity   bool
+	Key             *Key
+	Map             *Map
+	ProgramDateTime time.Time
+	Parts           []*Part  //the parts of the segment, they are listed before it
+	Tags            []string //unknown tags, kept as they are
+}
+
+// MediaPlaylist ...
+type MediaPlaylist struct {
+	Version               int
+	TargetDuration        int
+	MediaSequence         int64
+	DiscontinuitySequence int64
+	PlaylistType          string //VOD or EVENT
+	IFramesOnly           bool
+	IndependentSegments   bool
+	EndList               bool
+	ServerControl         *ServerControl
+	PartTarget            time.Duration
+	Segments              []*Segment
+	Parts                 []*Part //the parts of the incomplete segment after the last segment
+	PreloadHint           *PreloadHint
+	Tags                  []string //unknown header tags, kept as they are
+	Trailer               []string //unknown tags after the last segment
+}
+
+// Media EXT-X-MEDIA, an alternative rendition
+type Media struct {
+	Type            string
+	GroupID         string
+	Name            string
+	Language        string
+	AssocLanguage   string
+	Default         bool
+	AutoSelect      bool
+	Forced          bool
+	InstreamID      string
+	Characteristics string
+	Channels        string
+	URI             string
+}
+
+// Variant EXT-X-STREAM-INF with its URI, or EXT-X-I-FRAME-STREAM-INF
+type Variant struct {
+	Bandwidth        int64
+	AverageBandwidth int64
+	Codecs           string
+	Resolution       string
+	FrameRate        float64
+	HDCPLevel        string
+	Audio            string
+	Video            string
+	Subtitles        string
+	ClosedCaptions   string
+	URI              string
+}
+
+// MasterPlaylist ...
+type MasterPlaylist struct {
+	Version             int
+	IndependentSegments bool
+	Media               []*Media
+	Variants            []*Variant
+	IFrames             []*Variant
+	Tags                []string //unknown tags, kept as they are
+}
+
+// headerTags the unknown tags which belong to the playlist instead of a segment
+var headerTags = []string{
+	"#EXT-X-START",
+	"#EXT-X-ALLOW-CACHE",
+	"#EXT-X-DEFINE",
+}
+
+// Parse parse a media or a master playlist
+func Parse(r io.Reader) (Playlist, error) {
+	lines, e := readLines(r)
+	if e != nil {
+		return nil, e
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF") || strings.HasPrefix(line, "#EXT-X-MEDIA:") ||
+			strings.HasPrefix(line, "#EXT-X-I-FRAME-STREAM-INF") {
+			return parseMaster(lines)
+		}
+	}
+	return parseMedia(lines)
+}
+
+// ParseMedia ...
+func ParseMedia(r io.Reader) (*MediaPlaylist, error) {
+	p, e := Parse(r)
+	if e != nil {
+		return nil, e
+	}
+	m, ok := p.(*MediaPlaylist)
+	if !ok {
+		return nil, xerrors.New("not a media playlist")
+	}
+	return m, nil
+}
+
+// ParseMaster ...
+func ParseMaster(r io.Reader) (*MasterPlaylist, error) {
+	p, e := Parse(r)
+	if e != nil {
+		return nil, e
+	}
+	m, ok := p.(*MasterPlaylist)
+	if !ok {
+		return nil, xerrors.New("not a master playlist")
+	}
+	return m, nil
+}
+
+// ReadFile parse the playlist at path
+func ReadFile(path string) (Playlist, error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// WriteFile write p to a temporary file next to path and rename it into place,
+// readers never see a partially written playlist
+func WriteFile(path string, p Playlist) error {
+	var buf bytes.Buffer
+	if e := p.Encode(&buf); e != nil {
+		return e
+	}
+	tmp, e := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if e != nil {
+		return e
+	}
+	_, e = tmp.Write(buf.Bytes())
+	if err := tmp.Close(); e == nil {
+		e = err
+	}
+	if e == nil {
+		e = os.Chmod(tmp.Name(), 0644)
+	}
+	if e == nil {
+		e = os.Rename(tmp.Name(), path)
+	}
+	if e != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return e
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+	if len(lines) == 0 || lines[0] != "#EXTM3U" {
+		return nil, ErrNotPlaylist
+	}
+	return lines[1:], nil
+}
+
+// splitTag returns the name and the value of "#EXT-X-NAME:value"
+func splitTag(line string) (string, string) {
+	i := strings.IndexByte(line, ':')
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], line[i+1:]
+}
+
+func parseMedia(lines []string) (*MediaPlaylist, error) {
+	p := &MediaPlaylist{}
+	seg := &Segment{}
+	var e error
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "#") {
+			seg.URI = line
+			p.Segments = append(p.Segments, seg)
+			seg = &Segment{}
+			continue
+		}
+		if !strings.HasPrefix(line, "#EXT") {
+			//comment
+			continue
+		}
+		name, value := splitTag(line)
+		switch name {
+		case "#EXT-X-VERSION":
+			p.Version, e = strconv.Atoi(value)
+		case "#EXT-X-TARGETDURATION":
+			p.TargetDuration, e = strconv.Atoi(value)
+		case "#EXT-X-MEDIA-SEQUENCE":
+			p.MediaSequence, e = strconv.ParseInt(value, 10, 64)
+		case "#EXT-X-DISCONTINUITY-SEQUENCE":
+			p.DiscontinuitySequence, e = strconv.ParseInt(value, 10, 64)
+		case "#EXT-X-PLAYLIST-TYPE":
+			p.PlaylistType = value
+		case "#EXT-X-I-FRAMES-ONLY":
+			p.IFramesOnly = true
+		case "#EXT-X-INDEPENDENT-SEGMENTS":
+			p.IndependentSegments = true
+		case "#EXT-X-ENDLIST":
+			p.EndList = true
+		case "#EXTINF":
+			v := strings.SplitN(value, ",", 2)
+			seg.Duration, e = parseDuration(v[0])
+			if len(v) == 2 {
+				seg.Title = v[1]
+			}
+		case "#EXT-X-BYTERANGE":
+			seg.ByteRange, e = parseByteRange(value)
+		case "#EXT-X-DISCONTINUITY":
+			seg.Discontinuity = true
+		case "#EXT-X-KEY":
+			a := parseAttributes(value)
+			seg.Key = &Key{
+				Method:            a["METHOD"],
+				URI:               a["URI"],
+				IV:                a["IV"],
+				KeyFormat:         a["KEYFORMAT"],
+				KeyFormatVersions: a["KEYFORMATVERSIONS"],
+			}
+		case "#EXT-X-MAP":
+			a := parseAttributes(value)
+			seg.Map = &Map{URI: a["URI"]}
+			if v, ok := a["BYTERANGE"]; ok {
+				seg.Map.ByteRange, e = parseByteRange(v)
+			}
+		case "#EXT-X-PROGRAM-DATE-TIME":
+			seg.ProgramDateTime, e = parseDateTime(value)
+		case "#EXT-X-PART":
+			var part *Part
+			if part, e = parsePart(value); e == nil {
+				seg.Parts = append(seg.Parts, part)
+			}
+		case "#EXT-X-PRELOAD-HINT":
+			a := parseAttributes(value)
+			p.PreloadHint = &PreloadHint{Type: a["TYPE"], URI: a["URI"]}
+		case "#EXT-X-SERVER-CONTROL":
+			p.ServerControl, e = parseServerControl(value)
+		case "#EXT-X-PART-INF":
+			p.PartTarget, e = parseDuration(parseAttributes(value)["PART-TARGET"])
+		default:
+			if isHeaderTag(name) {
+				p.Tags = append(p.Tags, line)
+			} else {
+				seg.Tags = append(seg.Tags, line)
+			}
+		}
+		if e != nil {
+			return nil, xerrors.Errorf("m3u8: parse %q: %w", line, e)
+		}
+	}
+	p.Parts = seg.Parts
+	p.Trailer = seg.Tags
+	return p, nil
+}
+
+func parsePart(value string) (*Part, error) {
+	a := parseAttributes(value)
+	part := &Part{
+		URI:         a["URI"],
+		Independent: a["INDEPENDENT"] == "YES",
+		Gap:         a["GAP"] == "YES",
+	}
+	var e error
+	if part.Duration, e = parseDuration(a["DURATION"]); e != nil {
+		return nil, e
+	}
+	if v, ok := a["BYTERANGE"]; ok {
+		if part.ByteRange, e = parseByteRange(v); e != nil {
+			return nil, e
+		}
+	}
+	return part, nil
+}
+
+func parseServerControl(value string) (*ServerControl, error) {
+	a := parseAttributes(value)
+	sc := &ServerControl{CanBlockReload: a["CAN-BLOCK-RELOAD"] == "YES"}
+	for name, d := range map[string]*time.Duration{
+		"CAN-SKIP-UNTIL": &sc.CanSkipUntil,
+		"HOLD-BACK":      &sc.HoldBack,
+		"PART-HOLD-BACK": &sc.PartHoldBack,
+	} {
+		if v, ok := a[name]; ok {
+			var e error
+			if *d, e = parseDuration(v); e != nil {
+				return nil, e
+			}
+		}
+	}
+	return sc, nil
+}
+
+func isHeaderTag(name string) bool {
+	for _, t := range headerTags {
+		if name == t {
+			return true
+		}
+	}
+	return false
+}
+
+func parseMaster(lines []string) (*MasterPlaylist, error) {
+	p := &MasterPlaylist{}
+	var pending *Variant
+	var e error
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "#") {
+			if pending == nil {
+				return nil, xerrors.Errorf("m3u8: uri without EXT-X-STREAM-INF: %q", line)
+			}
+			pending.URI = line
+			p.Variants = append(p.Variants, pending)
+			pending = nil
+			continue
+		}
+		if !strings.HasPrefix(line, "#EXT") {
+			continue
+		}
+		name, value := splitTag(line)
+		switch name {
+		case "#EXT-X-VERSION":
+			p.Version, e = strconv.Atoi(value)
+		case "#EXT-X-INDEPENDENT-SEGMENTS":
+			p.IndependentSegments = true
+		case "#EXT-X-MEDIA":
+			a := parseAttributes(value)
+			p.Media = append(p.Media, &Media{
+				Type:            a["TYPE"],
+				GroupID:         a["GROUP-ID"],
+				Name:            a["NAME"],
+				Language:        a["LANGUAGE"],
+				AssocLanguage:   a["ASSOC-LANGUAGE"],
+				Default:         a["DEFAULT"] == "YES",
+				AutoSelect:      a["AUTOSELECT"] == "YES",
+				Forced:          a["FORCED"] == "YES",
+				InstreamID:      a["INSTREAM-ID"],
+				Characteristics: a["CHARACTERISTICS"],
+				Channels:        a["CHANNELS"],
+				URI:             a["URI"],
+			})
+		case "#EXT-X-STREAM-INF":
+			pending, e = parseVariant(value)
+		case "#EXT-X-I-FRAME-STREAM-INF":
+			var v *Variant
+			if v, e = parseVariant(value); e == nil {
+				p.IFrames = append(p.IFrames, v)
+			}
+		default:
+			p.Tags = append(p.Tags, line)
+		}
+		if e != nil {
+			return nil, xerrors.Errorf("m3u8: parse %q: %w", line, e)
+		}
+	}
+	return p, nil
+}
+
+func parseVariant(value string) (*Variant, error) {
+	a := parseAttributes(value)
+	v := &Variant{
+		Codecs:         a["CODECS"],
+		Resolution:     a["RESOLUTION"],
+		HDCPLevel:      a["HDCP-LEVEL"],
+		Audio:          a["AUDIO"],
+		Video:          a["VIDEO"],
+		Subtitles:      a["SUBTITLES"],
+		ClosedCaptions: a["CLOSED-CAPTIONS"],
+		URI:            a["URI"],
+	}
+	var e error
+	if v.Bandwidth, e = strconv.ParseInt(a["BANDWIDTH"], 10, 64); e != nil {
+		return nil, e
+	}
+	if s, ok := a["AVERAGE-BANDWIDTH"]; ok {
+		if v.AverageBandwidth, e = strconv.ParseInt(s, 10, 64); e != nil {
+			return nil, e
+		}
+	}
+	if s, ok := a["FRAME-RATE"]; ok {
+		if v.FrameRate, e = strconv.ParseFloat(s, 64); e != nil {
+			return nil, e
+		}
+	}
+	return v, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	f, e := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if e != nil {
+		return 0, e
+	}
+	return time.Duration(math.Round(f * float64(time.Second))), nil
+}
+
+// dateTimeLayouts ffmpeg writes the zone offset without a colon
+var dateTimeLayouts = []string{"2006-01-02T15:04:05.999999999Z07:00", "2006-01-02T15:04:05.999999999Z0700"}
+
+// parseDateTime parse an ISO 8601 date with the zone offset written with or without a colon
+func parseDateTime(s string) (t time.Time, e error) {
+	for _, layout := range dateTimeLayouts {
+		if t, e = time.Parse(layout, s); e == nil {
+			return t, nil
+		}
+	}
+	return t, e
+}
+
+// parseByteRange parse "length[@offset]"
+func parseByteRange(s string) (*ByteRange, error) {
+	v := strings.SplitN(s, "@", 2)
+	br := &ByteRange{Offset: -1}
+	var e error
+	if br.Length, e = strconv.ParseInt(v[0], 10, 64); e != nil {
+		return nil, e
+	}
+	if len(v) == 2 {
+		if br.Offset, e = strconv.ParseInt(v[1], 10, 64); e != nil {
+			return nil, e
+		}
+	}
+	return br, nil
+}
+
+// String ...
+func (br *ByteRange) String() string {
+	if br.Offset < 0 {
+		return strconv.FormatInt(br.Length, 10)
+	}
+	return fmt.Sprintf("%d@%d", br.Length, br.Offset)
+}
+
+// Duration returns the sum of the segment durations
+func (p *MediaPlaylist) Duration() time.Duration {
+	var d time.Duration
+	for _, s := range p.Segments {
+		d += s.Duration
+	}
+	return d
+}
+
+// MaxDuration returns the rounded up duration of the longest segment, the minimal TARGETDURATION
+func (p *MediaPlaylist) MaxDuration() int {
+	target := 0
+	for _, s := range p.Segments {
+		if t := int(math.Ceil(s.Duration.Seconds())); t > target {
+			target = t
+		}
+	}
+	return target
+}
+
+// Encode ...
+func (p *MediaPlaylist) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#EXTM3U\n")
+	if p.Version > 0 {
+		fmt.Fprintf(bw, "#EXT-X-VERSION:%d\n", p.Version)
+	}
+	fmt.Fprintf(bw, "#EXT-X-TARGETDURATION:%d\n", p.TargetDuration)
+	if sc := p.ServerControl; sc != nil {
+		a := &attributes{}
+		if sc.CanBlockReload {
+			a.enum("CAN-BLOCK-RELOAD", "YES")
+		}
+		a.enum("CAN-SKIP-UNTIL", formatSeconds(sc.CanSkipUntil))
+		a.enum("HOLD-BACK", formatSeconds(sc.HoldBack))
+		a.enum("PART-HOLD-BACK", formatSeconds(sc.PartHoldBack))
+		bw.WriteString("#EXT-X-SERVER-CONTROL:" + a.String() + "\n")
+	}
+	if p.PartTarget > 0 {
+		bw.WriteString("#EXT-X-PART-INF:PART-TARGET=" + formatSeconds(p.PartTarget) + "\n")
+	}
+	fmt.Fprintf(bw, "#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence)
+	if p.DiscontinuitySequence > 0 {
+		fmt.Fprintf(bw, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", p.DiscontinuitySequence)
+	}
+	if p.PlaylistType != "" {
+		fmt.Fprintf(bw, "#EXT-X-PLAYLIST-TYPE:%s\n", p.PlaylistType)
+	}
+	if p.IFramesOnly {
+		bw.WriteString("#EXT-X-I-FRAMES-ONLY\n")
+	}
+	if p.IndependentSegments {
+		bw.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+	writeTags(bw, p.Tags)
+	for _, s := range p.Segments {
+		s.encode(bw)
+	}
+	for _, part := range p.Parts {
+		part.encode(bw)
+	}
+	if p.PreloadHint != nil {
+		a := &attributes{}
+		a.enum("TYPE", p.PreloadHint.Type)
+		a.quoted("URI", p.PreloadHint.URI)
+		bw.WriteString("#EXT-X-PRELOAD-HINT:" + a.String() + "\n")
+	}
+	writeTags(bw, p.Trailer)
+	if p.EndList {
+		bw.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return bw.Flush()
+}
+
+func (s *Segment) encode(bw *bufio.Writer) {
+	if s.Discontinuity {
+		bw.WriteString("#EXT-X-DISCONTINUITY\n")
+	}
+	if s.Key != nil {
+		a := &attributes{}
+		a.enum("METHOD", s.Key.Method)
+		a.quoted("URI", s.Key.URI)
+		a.enum("IV", s.Key.IV)
+		a.quoted("KEYFORMAT", s.Key.KeyFormat)
+		a.quoted("KEYFORMATVERSIONS", s.Key.KeyFormatVersions)
+		bw.WriteString("#EXT-X-KEY:" + a.String() + "\n")
+	}
+	if s.Map != nil {
+		a := &attributes{}
+		a.quoted("URI", s.Map.URI)
+		if s.Map.ByteRange != nil {
+			a.quoted("BYTERANGE", s.Map.ByteRange.String())
+		}
+		bw.WriteString("#EXT-X-MAP:" + a.String() + "\n")
+	}
+	if !s.ProgramDateTime.IsZero() {
+		bw.WriteString("#EXT-X-PROGRAM-DATE-TIME:" + s.ProgramDateTime.Format("2006-01-02T15:04:05.000Z07:00") + "\n")
+	}
+	writeTags(bw, s.Tags)
+	for _, part := range s.Parts {
+		part.encode(bw)
+	}
+	fmt.Fprintf(bw, "#EXTINF:%.6f,%s\n", s.Duration.Seconds(), s.Title)
+	if s.ByteRange != nil {
+		bw.WriteString("#EXT-X-BYTERANGE:" + s.ByteRange.String() + "\n")
+	}
+	bw.WriteString(s.URI + "\n")
+}
+
+func (part *Part) encode(bw *bufio.Writer) {
+	a := &attributes{}
+	a.enum("DURATION", formatSeconds(part.Duration))
+	a.quoted("URI", part.URI)
+	if part.Independent {
+		a.enum("INDEPENDENT", "YES")
+	}
+	if part.ByteRange != nil {
+		a.quoted("BYTERANGE", part.ByteRange.String())
+	}
+	if part.Gap {
+		a.enum("GAP", "YES")
+	}
+	bw.WriteString("#EXT-X-PART:" + a.String() + "\n")
+}
+
+// formatSeconds format d as decimal seconds, 0 is empty
+func formatSeconds(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// Encode ...
+func (p *MasterPlaylist) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#EXTM3U\n")
+	if p.Version > 0 {
+		fmt.Fprintf(bw, "#EXT-X-VERSION:%d\n", p.Version)
+	}
+	if p.IndependentSegments {
+		bw.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+	writeTags(bw, p.Tags)
+	for _, m := range p.Media {
+		a := &attributes{}
+		a.enum("TYPE", m.Type)
+		a.quoted("GROUP-ID", m.GroupID)
+		a.quoted("NAME", m.Name)
+		a.quoted("LANGUAGE", m.Language)
+		a.quoted("ASSOC-LANGUAGE", m.AssocLanguage)
+		a.enum("DEFAULT", yesNo(m.Default))
+		a.enum("AUTOSELECT", yesNo(m.AutoSelect))
+		if m.Forced {
+			a.enum("FORCED", "YES")
+		}
+		a.quoted("INSTREAM-ID", m.InstreamID)
+		a.quoted("CHARACTERISTICS", m.Characteristics)
+		a.quoted("CHANNELS", m.Channels)
+		a.quoted("URI", m.URI)
+		bw.WriteString("#EXT-X-MEDIA:" + a.String() + "\n")
+	}
+	for _, v := range p.Variants {
+		bw.WriteString("#EXT-X-STREAM-INF:" + v.attributes(false).String() + "\n")
+		bw.WriteString(v.URI + "\n")
+	}
+	for _, v := range p.IFrames {
+		bw.WriteString("#EXT-X-I-FRAME-STREAM-INF:" + v.attributes(true).String() + "\n")
+	}
+	return bw.Flush()
+}
+
+func (v *Variant) attributes(iframe bool) *attributes {
+	a := &attributes{}
+	a.enum("BANDWIDTH", strconv.FormatInt(v.Bandwidth, 10))
+	if v.AverageBandwidth > 0 {
+		a.enum("AVERAGE-BANDWIDTH", strconv.FormatInt(v.AverageBandwidth, 10))
+	}
+	a.quoted("CODECS", v.Codecs)
+	a.enum("RESOLUTION", v.Resolution)
+	if v.FrameRate > 0 {
+		a.enum("FRAME-RATE", strconv.FormatFloat(v.FrameRate, 'f', 3, 64))
+	}
+	a.enum("HDCP-LEVEL", v.HDCPLevel)
+	a.quoted("AUDIO", v.Audio)
+	a.quoted("VIDEO", v.Video)
+	if !iframe {
+		a.quoted("SUBTITLES", v.Subtitles)
+		if v.ClosedCaptions == "NONE" {
+			a.enum("CLOSED-CAPTIONS", v.ClosedCaptions)
+		} else {
+			a.quoted("CLOSED-CAPTIONS", v.ClosedCaptions)
+		}
+	} else {
+		a.quoted("URI", v.URI)
+	}
+	return a
+}
+
+func writeTags(bw *bufio.Writer, tags []string) {
+	for _, t := range tags {
+		bw.WriteString(t + "\n")
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
@@ -0,0 +1,208 @@
+package m3u8
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMedia = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:3
+#EXT-X-DISCONTINUITY-SEQUENCE:1
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-START:TIME-OFFSET=5
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin?a=1,b=2",IV=0x00000000000000000000000000000001
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="720@0"
+#EXT-X-PROGRAM-DATE-TIME:2020-01-02T03:04:05.678Z
+#EXTINF:9.009000,first
+#EXT-X-BYTERANGE:1000@720
+media.mp4
+# a comment
+#EXT-X-DISCONTINUITY
+#EXT-X-CUE-OUT:30
+#EXTINF:4.5,
+#EXT-X-BYTERANGE:2000
+media.mp4
+#EXT-X-ENDLIST
+`
+
+// TestParseMedia ...
+func TestParseMedia(t *testing.T) {
+	p, e := ParseMedia(strings.NewReader(testMedia))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if p.Version != 7 || p.TargetDuration != 10 || p.MediaSequence != 3 || p.DiscontinuitySequence != 1 ||
+		p.PlaylistType != "VOD" || !p.IndependentSegments || !p.EndList || len(p.Tags) != 1 {
+		t.Fatalf("%+v", p)
+	}
+	if len(p.Segments) != 2 {
+		t.Fatalf("%+v", p.Segments)
+	}
+	s := p.Segments[0]
+	if s.Duration != 9009*time.Millisecond || s.Title != "first" || *s.ByteRange != (ByteRange{1000, 720}) || s.URI != "media.mp4" {
+		t.Fatalf("%+v", s)
+	}
+	if s.Key == nil || s.Key.URI != "key.bin?a=1,b=2" || s.Key.IV != "0x00000000000000000000000000000001" {
+		t.Fatalf("%+v", s.Key)
+	}
+	if s.Map == nil || s.Map.URI != "init.mp4" || *s.Map.ByteRange != (ByteRange{720, 0}) {
+		t.Fatalf("%+v", s.Map)
+	}
+	if s.ProgramDateTime.UnixNano() != time.Date(2020, 1, 2, 3, 4, 5, 678e6, time.UTC).UnixNano() {
+		t.Fatal(s.ProgramDateTime)
+	}
+	s = p.Segments[1]
+	if !s.Discontinuity || s.Key != nil || s.ByteRange.Offset != -1 || len(s.Tags) != 1 || s.Tags[0] != "#EXT-X-CUE-OUT:30" {
+		t.Fatalf("%+v", s)
+	}
+	if p.Duration() != 13509*time.Millisecond || p.MaxDuration() != 10 {
+		t.Fatal(p.Duration(), p.MaxDuration())
+	}
+
+	var buf bytes.Buffer
+	if e := p.Encode(&buf); e != nil {
+		t.Fatal(e)
+	}
+	for _, want := range []string{
+		"#EXT-X-START:TIME-OFFSET=5\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin?a=1,b=2\",IV=0x00000000000000000000000000000001\n",
+		"#EXT-X-MAP:URI=\"init.mp4\",BYTERANGE=\"720@0\"\n#EXT-X-PROGRAM-DATE-TIME:2020-01-02T03:04:05.678Z\n#EXTINF:9.009000,first\n#EXT-X-BYTERANGE:1000@720\nmedia.mp4\n",
+		"#EXT-X-DISCONTINUITY\n#EXT-X-CUE-OUT:30\n#EXTINF:4.500000,\n#EXT-X-BYTERANGE:2000\nmedia.mp4\n#EXT-X-ENDLIST\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in\n%s", want, buf.String())
+		}
+	}
+	p2, e := ParseMedia(&buf)
+	if e != nil || len(p2.Segments) != 2 || p2.Segments[1].Duration != p.Segments[1].Duration {
+		t.Fatal(e, p2)
+	}
+}
+
+const testMaster = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="English, main",LANGUAGE="eng",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="audio_eng.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="jpn",LANGUAGE="jpn",DEFAULT=NO,AUTOSELECT=YES,FORCED=YES,URI="subs_jpn.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,AVERAGE-BANDWIDTH=1500000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=29.970,AUDIO="audio",SUBTITLES="subs",CLOSED-CAPTIONS=NONE
+video_720.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=100000,RESOLUTION=1280x720,URI="iframe_720.m3u8"
+`
+
+// TestParseMaster ...
+func TestParseMaster(t *testing.T) {
+	p, e := ParseMaster(strings.NewReader(testMaster))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(p.Media) != 2 || p.Media[0].Name != "English, main" || !p.Media[0].Default || p.Media[0].Channels != "2" || !p.Media[1].Forced {
+		t.Fatalf("%+v", p.Media)
+	}
+	if len(p.Variants) != 1 || len(p.IFrames) != 1 {
+		t.Fatalf("%+v %+v", p.Variants, p.IFrames)
+	}
+	v := p.Variants[0]
+	if v.Bandwidth != 2000000 || v.AverageBandwidth != 1500000 || v.Codecs != "avc1.64001f,mp4a.40.2" || v.FrameRate != 29.97 ||
+		v.Audio != "audio" || v.ClosedCaptions != "NONE" || v.URI != "video_720.m3u8" {
+		t.Fatalf("%+v", v)
+	}
+	if p.IFrames[0].URI != "iframe_720.m3u8" {
+		t.Fatalf("%+v", p.IFrames[0])
+	}
+	var buf bytes.Buffer
+	if e := p.Encode(&buf); e != nil {
+		t.Fatal(e)
+	}
+	if buf.String() != testMaster {
+		t.Fatalf("%s\n!=\n%s", buf.String(), testMaster)
+	}
+	if _, e := ParseMedia(strings.NewReader(testMaster)); e == nil {
+		t.Fatal("want media playlist error")
+	}
+	if _, e := Parse(strings.NewReader("video.ts\n")); e != ErrNotPlaylist {
+		t.Fatal(e)
+	}
+}
+
+// TestWriteFile ...
+func TestWriteFile(t *testing.T) {
+	dir, e := ioutil.TempDir("", "m3u8")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "media.m3u8")
+	p := &MediaPlaylist{Version: 3, TargetDuration: 2, EndList: true, Segments: []*Segment{{URI: "a.ts", Duration: 2 * time.Second}}}
+	if e := WriteFile(path, p); e != nil {
+		t.Fatal(e)
+	}
+	read, e := ReadFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if m, ok := read.(*MediaPlaylist); !ok || len(m.Segments) != 1 || m.Segments[0].URI != "a.ts" {
+		t.Fatalf("%+v", read)
+	}
+	infos, _ := ioutil.ReadDir(dir)
+	if len(infos) != 1 {
+		t.Fatal("temporary file left", infos)
+	}
+}
+
+const testLowLatency = `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=1.5
+#EXT-X-PART-INF:PART-TARGET=0.5
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-PART:DURATION=0.5,URI="part-00080.ts",INDEPENDENT=YES
+#EXT-X-PART:DURATION=0.5,URI="part-00081.ts"
+#EXTINF:1.000000,
+media-00010.ts
+#EXT-X-PART:DURATION=0.5,URI="part-00082.ts",INDEPENDENT=YES
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="part-00083.ts"
+`
+
+// TestParseMedia_LowLatency ...
+func TestParseMedia_LowLatency(t *testing.T) {
+	p, e := ParseMedia(strings.NewReader(testLowLatency))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if p.ServerControl == nil || !p.ServerControl.CanBlockReload || p.ServerControl.PartHoldBack != 1500*time.Millisecond || p.PartTarget != 500*time.Millisecond {
+		t.Fatalf("%+v %+v", p, p.ServerControl)
+	}
+	if len(p.Segments) != 1 || len(p.Segments[0].Parts) != 2 || !p.Segments[0].Parts[0].Independent || p.Segments[0].Parts[1].Independent {
+		t.Fatalf("%+v", p.Segments)
+	}
+	if len(p.Parts) != 1 || p.Parts[0].URI != "part-00082.ts" || p.PreloadHint == nil || p.PreloadHint.URI != "part-00083.ts" {
+		t.Fatalf("%+v %+v", p.Parts, p.PreloadHint)
+	}
+	var buf bytes.Buffer
+	if e := p.Encode(&buf); e != nil {
+		t.Fatal(e)
+	}
+	if buf.String() != testLowLatency {
+		t.Fatalf("%s\n!=\n%s", buf.String(), testLowLatency)
+	}
+}
+
+// TestParseMedia_ProgramDateTime ...
+func TestParseMedia_ProgramDateTime(t *testing.T) {
+	want := time.Date(2024, 1, 1, 8, 0, 0, 500000000, time.UTC)
+	for _, pdt := range []string{"2024-01-01T08:00:00.500Z", "2024-01-01T10:00:00.500+02:00", "2024-01-01T08:00:00.500+0000", "2024-01-01T10:00:00.500+0200"} {
+		p, e := ParseMedia(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-PROGRAM-DATE-TIME:" + pdt + "\n#EXTINF:2.000000,\nmedia-00000.ts\n"))
+		if e != nil {
+			t.Fatal(pdt, e)
+		}
+		if !p.Segments[0].ProgramDateTime.Equal(want) {
+			t.Fatal(pdt, p.Segments[0].ProgramDateTime)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package fftool
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// OutputNaming returns the directory name of the auto output
+type OutputNaming func(file string, sa *SplitArgs) (string, error)
+
+// UUIDNaming name the output with a random uuid
+func UUIDNaming(file string, sa *SplitArgs) (string, error) {
+	return uuid.New().String(), nil
+}
+
+// ContentNaming name the output with the hash of the input content and the effective encode settings,
+// the same source with the same settings reuse the existing output
+func ContentNaming(file string, sa *SplitArgs) (string, error) {
+	f, e := os.Open(file)
+	if e != nil {
+		return "", e
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, e := io.Copy(h, f); e != nil {
+		return "", e
+	}
+	_, _ = io.WriteString(h, sa.settings())
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// settings returns the encode settings which affect the output
+func (sa *SplitArgs) settings() string {
+	groups := []string{
+		fmt.Sprintf("video=%s,audio=%s,scale=%d,bitrate=%d,framerate=%v,hlstime=%d,m3u8=%s,segment=%s,map=%s",
+			sa.Video, sa.Audio, sa.Scale, sa.BitRate, sa.FrameRate, sa.HLSTime, sa.M3U8, sa.SegmentFileName,
+			mapArgs(sa.VideoStream, sa.AudioStream)),
+		sa.audioArgs(),
+		sa.renditionSettings(),
+		fmt.Sprintf(",burn=%+v,iframes=%t,gop=%t", sa.Burn, sa.iframes, sa.gopAlign),
+		sa.thumbnailSettings(),
+		sa.cueSettings(),
+		sa.audioSettings(),
+	}
+	//every group carries its separator, so the names of the existing outputs do not change
+	return strings.Join(groups, "")
+}
+
+// tempOutput returns a temporary sibling of output
+func tempOutput(output string) string {
+	return filepath.Join(filepath.Dir(output), "."+filepath.Base(output)+"."+uuid.New().String()+".tmp")
+}
+
+// finishOutput move work to output on success, or remove it on failure unless keep is set
+func finishOutput(work, output string, e error, keep bool) error {
+	if e != nil {
+		if keep {
+			log.With("output", work).Warn("keep failed output")
+			return e
+		}
+		if err := os.RemoveAll(work); err != nil {
+			log.Error(err)
+		}
+		return e
+	}
+	if e := os.Rename(work, output); e != nil {
+		//the same content may finished by another job
+		if _, err := os.Stat(output); err == nil {
+			log.With("output", output).Warn("output exists")
+			return os.RemoveAll(work)
+		}
+		return e
+	}
+	return nil
+}
+
+// renditionSettings ...
+func (sa *SplitArgs) renditionSettings() string {
+	var s []string
+	for _, r := range sa.Renditions {
+		s = append(s, fmt.Sprintf(",%s=%s:%s:%s", strings.ToLower(r.Type), r.URI, r.Language, filepath.Base(r.File)))
+	}
+	return strings.Join(s, "")
+}
+
+// thumbnailSettings returns the settings of the thumbnails and the sprites
+func (sa *SplitArgs) thumbnailSettings() string {
+	var s []string
+	for _, t := range sa.thumbnails {
+		s = append(s, fmt.Sprintf(",thumbnail=%d:%s:%d:%s:%dx%d:%s:%s", t.Mode, t.Time, t.Count, t.Interval, t.Width, t.Height, t.Format, t.Name))
+	}
+	for _, p := range sa.sprites {
+		s = append(s, fmt.Sprintf(",sprite=%s:%dx%d:%dx%d:%s:%s", p.Interval, p.Columns, p.Rows, p.Width, p.Height, p.Format, p.Name))
+	}
+	return strings.Join(s, "")
+}
@@ -0,0 +1,109 @@
+package fftool
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestFinishOutput ...
+func TestFinishOutput(t *testing.T) {
+	dir, e := ioutil.TempDir("", "fftool")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "package")
+	work := tempOutput(output)
+	_ = os.MkdirAll(work, os.ModePerm)
+	if e := finishOutput(work, output, nil, false); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := os.Stat(output); e != nil {
+		t.Fatal(e)
+	}
+
+	failed := filepath.Join(dir, "failed")
+	work = tempOutput(failed)
+	_ = os.MkdirAll(work, os.ModePerm)
+	_ = finishOutput(work, failed, errors.New("failed"), false)
+	if _, e := os.Stat(work); !os.IsNotExist(e) {
+		t.Fatal("temporary output not removed", e)
+	}
+	if _, e := os.Stat(failed); !os.IsNotExist(e) {
+		t.Fatal("failed output exists", e)
+	}
+
+	_ = os.MkdirAll(work, os.ModePerm)
+	_ = finishOutput(work, failed, errors.New("failed"), true)
+	if _, e := os.Stat(work); e != nil {
+		t.Fatal("temporary output removed with debug", e)
+	}
+}
+
+// TestFFMpegSplitLookup ...
+func TestFFMpegSplitLookup(t *testing.T) {
+	dir, e := ioutil.TempDir("", "fftool")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "video.mp4")
+	_ = ioutil.WriteFile(file, []byte("content"), 0644)
+
+	sa, exist, e := FFMpegSplitLookup(file, OutputOption(dir), NamingOption(ContentNaming))
+	if e != nil || exist {
+		t.Fatal(exist, e)
+	}
+	sa2, _, _ := FFMpegSplitLookup(file, OutputOption(dir), NamingOption(ContentNaming))
+	if sa.Output != sa2.Output {
+		t.Fatal("content naming is not deterministic", sa.Output, sa2.Output)
+	}
+	sa3, _, _ := FFMpegSplitLookup(file, OutputOption(dir), NamingOption(ContentNaming), HLSTimeOption(6))
+	if sa.Output == sa3.Output {
+		t.Fatal("settings are not part of the name")
+	}
+
+	_ = os.MkdirAll(sa.Output, os.ModePerm)
+	_ = ioutil.WriteFile(filepath.Join(sa.Output, sa.M3U8), []byte("#EXTM3U\n"), 0644)
+	if _, exist, _ = FFMpegSplitLookup(file, OutputOption(dir), NamingOption(ContentNaming)); !exist {
+		t.Fatal("want exist output")
+	}
+}
+
+// TestFFMpegRun_CancelWait ...
+func TestFFMpegRun_CancelWait(t *testing.T) {
+	dir, e := ioutil.TempDir("", "cancel")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "pid")
+	defer fakeCommand(t, "ffmpeg", "echo $$ > "+pidFile+"\nexec sleep 10\n")()
+	ctx := FFmpegContext()
+	go func() {
+		for {
+			if _, e := os.Stat(pidFile); e == nil {
+				ctx.Cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	if e := FFMpegRun(ctx, "-i video.mkv"); e != context.Canceled {
+		t.Fatal(e)
+	}
+	b, _ := ioutil.ReadFile(pidFile)
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	//the process is reaped before the run returns
+	if p, _ := os.FindProcess(pid); p.Signal(syscall.Signal(0)) == nil {
+		t.Fatal("process is running", pid)
+	}
+}
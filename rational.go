@@ -0,0 +1,154 @@
+package fftool
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Rational ...
+type Rational struct {
+	Num int64
+	Den int64
+}
+
+// NewRational returns the reduced rational of num/den
+func NewRational(num, den int64) Rational {
+	if den == 0 {
+		return Rational{}
+	}
+	r := new(big.Rat).SetFrac64(num, den)
+	return Rational{Num: r.Num().Int64(), Den: r.Denom().Int64()}
+}
+
+// ParseRational parse "30000/1001", "16:9", "25" or "29.97"
+func ParseRational(s string) (Rational, error) {
+	v := strings.Replace(strings.TrimSpace(s), ":", "/", 1)
+	r, ok := new(big.Rat).SetString(v)
+	if !ok {
+		return Rational{}, xerrors.Errorf("malformed rational:%q", s)
+	}
+	if !r.Num().IsInt64() || !r.Denom().IsInt64() {
+		return Rational{}, xerrors.Errorf("rational out of range:%q", s)
+	}
+	return Rational{Num: r.Num().Int64(), Den: r.Denom().Int64()}, nil
+}
+
+// IsZero ...
+func (r Rational) IsZero() bool {
+	return r.Num == 0 || r.Den == 0
+}
+
+// Float64 ...
+func (r Rational) Float64() float64 {
+	if r.Den == 0 {
+		return 0
+	}
+	return float64(r.Num) / float64(r.Den)
+}
+
+// Cmp compares r and o exactly, returns -1, 0 or 1
+func (r Rational) Cmp(o Rational) int {
+	return r.rat().Cmp(o.rat())
+}
+
+// String ...
+func (r Rational) String() string {
+	if r.Den == 1 {
+		return strconv.FormatInt(r.Num, 10)
+	}
+	return strconv.FormatInt(r.Num, 10) + "/" + strconv.FormatInt(r.Den, 10)
+}
+
+func (r Rational) rat() *big.Rat {
+	if r.Den == 0 {
+		return new(big.Rat)
+	}
+	return big.NewRat(r.Num, r.Den)
+}
+
+// parseSeconds parse ffprobe seconds like "10.010000"
+func parseSeconds(s string) (time.Duration, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, xerrors.Errorf("malformed seconds:%q", s)
+	}
+	r.Mul(r, big.NewRat(int64(time.Second), 1))
+	f, _ := r.Float64()
+	return time.Duration(f), nil
+}
+
+// parseInt ...
+func parseInt(s string) (int64, error) {
+	i, e := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if e != nil {
+		return 0, xerrors.Errorf("malformed integer:%q", s)
+	}
+	return i, nil
+}
+
+// FrameRate returns the r_frame_rate
+func (s *Stream) FrameRate() (Rational, error) {
+	return parseRate(s.RFrameRate)
+}
+
+// AvgFrameRateValue returns the avg_frame_rate
+func (s *Stream) AvgFrameRateValue() (Rational, error) {
+	return parseRate(s.AvgFrameRate)
+}
+
+// TimeBaseValue returns the time_base
+func (s *Stream) TimeBaseValue() (Rational, error) {
+	return parseRate(s.TimeBase)
+}
+
+// DurationValue ...
+func (s *Stream) DurationValue() (time.Duration, error) {
+	return parseSeconds(s.Duration)
+}
+
+// BitRateValue returns the bit rate in bits per second
+func (s *Stream) BitRateValue() (int64, error) {
+	return parseInt(s.BitRate)
+}
+
+// StartTimeValue ...
+func (f *Format) StartTimeValue() (time.Duration, error) {
+	return parseSeconds(f.StartTime)
+}
+
+// DurationValue ...
+func (f *Format) DurationValue() (time.Duration, error) {
+	return parseSeconds(f.Duration)
+}
+
+// BitRateValue returns the bit rate in bits per second
+func (f *Format) BitRateValue() (int64, error) {
+	return parseInt(f.BitRate)
+}
+
+// SizeBytes ...
+func (f *Format) SizeBytes() (int64, error) {
+	return parseInt(f.Size)
+}
+
+// parseRate parse a rate, ffprobe use 0/0 for unknown
+func parseRate(s string) (Rational, error) {
+	//big.Rat rejects a zero denominator as malformed
+	if i := strings.IndexAny(s, "/:"); i >= 0 {
+		if den, e := parseInt(s[i+1:]); e == nil && den == 0 {
+			return Rational{}, xerrors.Errorf("unknown rate:%q", s)
+		}
+	}
+	r, e := ParseRational(s)
+	if e != nil {
+		return Rational{}, e
+	}
+	if r.IsZero() {
+		return Rational{}, xerrors.Errorf("unknown rate:%q", s)
+	}
+	return r, nil
+}
@@ -0,0 +1,78 @@
+package fftool
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseRational ...
+func TestParseRational(t *testing.T) {
+	cases := map[string]Rational{
+		"30000/1001": {Num: 30000, Den: 1001},
+		"16:9":       {Num: 16, Den: 9},
+		"50/2":       {Num: 25, Den: 1},
+		"25":         {Num: 25, Den: 1},
+		"29.97":      {Num: 2997, Den: 100},
+	}
+	for s, want := range cases {
+		r, e := ParseRational(s)
+		if e != nil || r != want {
+			t.Errorf("%s:got %v,%v want %v", s, r, e, want)
+		}
+	}
+	for _, s := range []string{"", "N/A", "0/0", "1/x"} {
+		if _, e := ParseRational(s); e == nil {
+			t.Errorf("%s:want error", s)
+		}
+	}
+	//ffprobe reports 0/0 for the streams without a rate
+	if _, e := parseRate("0/0"); e == nil || !strings.Contains(e.Error(), "unknown rate") {
+		t.Errorf("0/0:got %v", e)
+	}
+	if NewRational(24000, 1001).Cmp(Rational{Num: 48000, Den: 2002}) != 0 {
+		t.Error("want equal")
+	}
+	if (Rational{Num: 30000, Den: 1001}).Cmp(Rational{Num: 30, Den: 1}) >= 0 {
+		t.Error("want less")
+	}
+}
+
+// TestStream_Values ...
+func TestStream_Values(t *testing.T) {
+	sf := testStreamFormat()
+	video := sf.Video()
+	if fr, e := video.FrameRate(); e != nil || fr.String() != "24000/1001" {
+		t.Fatal(fr, e)
+	}
+	if tb, e := video.TimeBaseValue(); e != nil || tb != (Rational{Num: 1, Den: 24000}) {
+		t.Fatal(tb, e)
+	}
+	if b, e := video.BitRateValue(); e != nil || b != 4000000 {
+		t.Fatal(b, e)
+	}
+	if _, e := video.DurationValue(); e == nil {
+		t.Fatal("want error for empty duration")
+	}
+	if d, e := sf.Format.DurationValue(); e != nil || d != 100*time.Second {
+		t.Fatal(d, e)
+	}
+	if s, e := sf.Format.SizeBytes(); e != nil || s != 52400000 {
+		t.Fatal(s, e)
+	}
+}
+
+// TestOptimizeScale_FrameRate ...
+func TestOptimizeScale_FrameRate(t *testing.T) {
+	sf := testStreamFormat()
+	sa := &SplitArgs{Scale: 720}
+	optimizeScale(sa, sf.Video())
+	if sa.FrameRate != (Rational{Num: 24000, Den: 1001}) {
+		t.Fatal(sa.FrameRate)
+	}
+	sa = &SplitArgs{Scale: 720, FrameRate: Rational{Num: 30, Den: 1}}
+	optimizeScale(sa, sf.Video())
+	if !sa.FrameRate.IsZero() {
+		t.Fatal("frame rate above source", sa.FrameRate)
+	}
+}
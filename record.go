@@ -0,0 +1,424 @@
+package fftool
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// RecordIndexName the index of the recorded files in the output directory
+const RecordIndexName = "index.json"
+
+// ErrRecordRange ...
+var ErrRecordRange = xerrors.New("no recorded file in range")
+
+// RecordFile a recorded file with its wall-clock times
+type RecordFile struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// RecordIndex ...
+type RecordIndex struct {
+	Files []RecordFile `json:"files"`
+}
+
+// ReadRecordIndex read the index of the recording in dir, a missing index is empty
+func ReadRecordIndex(dir string) (*RecordIndex, error) {
+	idx := &RecordIndex{}
+	b, e := ioutil.ReadFile(filepath.Join(dir, RecordIndexName))
+	if os.IsNotExist(e) {
+		return idx, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	if e := json.Unmarshal(b, idx); e != nil {
+		return nil, e
+	}
+	return idx, nil
+}
+
+// write write the index to dir through a temporary file
+func (idx *RecordIndex) write(dir string) error {
+	b, e := json.MarshalIndent(idx, "", "  ")
+	if e != nil {
+		return e
+	}
+	path := filepath.Join(dir, RecordIndexName)
+	if e := ioutil.WriteFile(path+".tmp", b, 0644); e != nil {
+		return e
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Range returns the files overlapping the wall-clock range from to
+func (idx *RecordIndex) Range(from, to time.Time) []RecordFile {
+	var files []RecordFile
+	for _, f := range idx.Files {
+		if f.End.After(from) && f.Start.Before(to) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// RecordArgs ...
+type RecordArgs struct {
+	Input        string
+	Output       string
+	FileName     string        //strftime pattern of the files, the run number is added before the extension
+	Segment      time.Duration //length of a file
+	IndexPoll    time.Duration //interval of the index updates
+	InputTimeout time.Duration //the input is dropped when no data is read for InputTimeout
+	MaxRestarts  int           //restarts after an input drop, -1 is unlimited
+	RestartDelay time.Duration
+	Limits       *Limits
+}
+
+// RecordOptions ...
+type RecordOptions func(args *RecordArgs)
+
+// RecordOutputOption ...
+func RecordOutputOption(dir string) RecordOptions {
+	return func(args *RecordArgs) {
+		args.Output = dir
+	}
+}
+
+// RecordFileNameOption set the strftime pattern of the files, the extension selects the container
+func RecordFileNameOption(pattern string) RecordOptions {
+	return func(args *RecordArgs) {
+		args.FileName = pattern
+	}
+}
+
+// RecordSegmentOption rotate the file every d
+func RecordSegmentOption(d time.Duration) RecordOptions {
+	return func(args *RecordArgs) {
+		args.Segment = d
+	}
+}
+
+// RecordRestartOption restart ffmpeg at most n times after an input drop, -1 is unlimited
+func RecordRestartOption(n int, delay time.Duration) RecordOptions {
+	return func(args *RecordArgs) {
+		args.MaxRestarts = n
+		args.RestartDelay = delay
+	}
+}
+
+// RecordInputTimeoutOption ...
+func RecordInputTimeoutOption(d time.Duration) RecordOptions {
+	return func(args *RecordArgs) {
+		args.InputTimeout = d
+	}
+}
+
+// RecordLimitsOption ...
+func RecordLimitsOption(l Limits) RecordOptions {
+	return func(args *RecordArgs) {
+		args.Limits = &l
+	}
+}
+
+// Recorder a running recording
+type Recorder struct {
+	Args     *RecordArgs
+	ctx      Context
+	done     chan struct{}
+	mu       sync.Mutex
+	restarts int
+	e        error
+	index    *RecordIndex
+	recorded int //files of the finished runs in index
+}
+
+// FFMpegRecord copy the input into rotating files in the output until Stop, the index of the
+// finished files is kept in RecordIndexName and a previous recording in the output is continued
+func FFMpegRecord(ctx Context, input string, opts ...RecordOptions) (*Recorder, error) {
+	ra := &RecordArgs{
+		Input:        input,
+		Output:       ".",
+		FileName:     "record-%Y%m%d-%H%M%S.ts",
+		Segment:      10 * time.Minute,
+		IndexPoll:    time.Second,
+		InputTimeout: 10 * time.Second,
+		MaxRestarts:  -1,
+		RestartDelay: time.Second,
+	}
+	for _, o := range opts {
+		o(ra)
+	}
+	var e error
+	if ra.Output, e = filepath.Abs(ra.Output); e != nil {
+		return nil, e
+	}
+	if e := os.MkdirAll(ra.Output, os.ModePerm); e != nil {
+		return nil, e
+	}
+	idx, e := ReadRecordIndex(ra.Output)
+	if e != nil {
+		return nil, e
+	}
+	r := &Recorder{Args: ra, ctx: childContext(ctx), done: make(chan struct{}), index: idx, recorded: len(idx.Files)}
+	go r.run()
+	return r, nil
+}
+
+// args returns the ffmpeg arguments of run, the segment muxer writes the finished files to list
+func (ra *RecordArgs) args(list string, run int) []string {
+	args := []string{"-y"}
+	in, out := ra.Limits.args()
+	args = append(args, strings.Fields(in)...)
+	if ra.InputTimeout > 0 {
+		args = append(args, "-rw_timeout", fmt.Sprint(int64(ra.InputTimeout/time.Microsecond)))
+	}
+	args = append(args, "-i", ra.Input, "-map", "0", "-c", "copy")
+	args = append(args, strings.Fields(out)...)
+	return append(args, "-f", "segment", "-segment_time", strconv.FormatFloat(ra.Segment.Seconds(), 'f', -1, 64),
+		"-segment_list", list, "-segment_list_type", "csv", "-reset_timestamps", "1", "-strftime", "1",
+		filepath.Join(ra.Output, ra.fileName(run)))
+}
+
+// fileName returns the file pattern of run, a restart in the same second does not overwrite
+// the files of the previous run
+func (ra *RecordArgs) fileName(run int) string {
+	ext := filepath.Ext(ra.FileName)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(ra.FileName, ext), run, ext)
+}
+
+// run record until the input ended, the context is canceled or the restarts are exhausted
+func (r *Recorder) run() {
+	defer close(r.done)
+	ra := r.Args
+	for {
+		e := r.record()
+		if r.ctx.Context().Err() != nil {
+			return
+		}
+		r.mu.Lock()
+		if e == nil || ra.MaxRestarts >= 0 && r.restarts >= ra.MaxRestarts {
+			r.e = e
+			r.mu.Unlock()
+			return
+		}
+		r.restarts++
+		r.mu.Unlock()
+		log.With("input", ra.Input, "restarts", r.Restarts()).Warn("record input dropped, restart", e)
+		select {
+		case <-time.After(ra.RestartDelay):
+		case <-r.ctx.Context().Done():
+			return
+		}
+	}
+}
+
+// record run ffmpeg once and update the index while it runs, ffmpegRun returns after the process
+// exited so the last update reads the complete list
+func (r *Recorder) record() error {
+	ra := r.Args
+	run := r.Restarts()
+	list := filepath.Join(ra.Output, fmt.Sprintf(".record-%d.csv", run))
+	defer os.Remove(list)
+	ffmpeg := NewFFMpeg()
+	ffmpeg.Args = ra.args(list, run)
+	ffmpeg.Limits = ra.Limits
+	//the segment muxer closes the last file and adds it to the list on SIGINT
+	ffmpeg.Interrupt = true
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- ffmpegRun(r.ctx, ffmpeg)
+	}()
+	ticker := time.NewTicker(ra.IndexPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if e := r.update(list, start, false); e != nil {
+				log.With("list", list).Error(e)
+			}
+		case e := <-done:
+			if err := r.update(list, start, true); err != nil {
+				log.With("list", list).Error(err)
+			}
+			return e
+		}
+	}
+}
+
+// update add the files of the segment list to the index, the wall-clock times are
+// the times of the list relative to start, finish keeps them for the next runs
+func (r *Recorder) update(list string, start time.Time, finish bool) error {
+	files, e := readSegmentList(list, start)
+	if e != nil && !os.IsNotExist(e) {
+		return e
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.index.Files = append(r.index.Files[:r.recorded], files...)
+	if finish {
+		r.recorded = len(r.index.Files)
+	}
+	return r.index.write(r.Args.Output)
+}
+
+// readSegmentList read the csv list of the segment muxer
+func readSegmentList(list string, start time.Time) ([]RecordFile, error) {
+	f, e := os.Open(list)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+	rd := csv.NewReader(f)
+	rd.FieldsPerRecord = 3
+	var files []RecordFile
+	var base float64
+	for {
+		record, e := rd.Read()
+		if e != nil {
+			//io.EOF or the last line is being written
+			return files, nil
+		}
+		s, e1 := strconv.ParseFloat(record[1], 64)
+		end, e2 := strconv.ParseFloat(record[2], 64)
+		if e1 != nil || e2 != nil {
+			return nil, xerrors.Errorf("wrong segment list line:%v", record)
+		}
+		if files == nil {
+			base = s
+		}
+		files = append(files, RecordFile{
+			Name:  filepath.Base(record[0]),
+			Start: start.Add(time.Duration((s - base) * float64(time.Second))),
+			End:   start.Add(time.Duration((end - base) * float64(time.Second))),
+		})
+	}
+}
+
+// Index returns a copy of the index
+func (r *Recorder) Index() *RecordIndex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &RecordIndex{Files: append([]RecordFile(nil), r.index.Files...)}
+}
+
+// Restarts returns the restarts after an input drop
+func (r *Recorder) Restarts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.restarts
+}
+
+// Done is closed when the recording ended
+func (r *Recorder) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait ...
+func (r *Recorder) Wait() error {
+	<-r.done
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.e
+}
+
+// Stop interrupt ffmpeg and wait until the file being written is closed and indexed,
+// the context passed to FFMpegRecord is not canceled
+func (r *Recorder) Stop() error {
+	r.ctx.Cancel()
+	return r.Wait()
+}
+
+// RecordExportArgs ...
+type RecordExportArgs struct {
+	HLSTime         int
+	SegmentFileName string
+}
+
+// RecordExportOptions ...
+type RecordExportOptions func(args *RecordExportArgs)
+
+// RecordExportHLSOption set the segment length and the segment names of a HLS export
+func RecordExportHLSOption(hlsTime int, segment string) RecordExportOptions {
+	return func(args *RecordExportArgs) {
+		args.HLSTime = hlsTime
+		args.SegmentFileName = segment
+	}
+}
+
+// concatList returns the ffconcat list of files trimmed to the range from to
+func concatList(files []RecordFile, from, to time.Time) string {
+	var b strings.Builder
+	b.WriteString("ffconcat version 1.0\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "file '%s'\n", strings.Replace(f.Name, "'", `'\''`, -1))
+		if from.After(f.Start) {
+			fmt.Fprintf(&b, "inpoint %.6f\n", from.Sub(f.Start).Seconds())
+		}
+		if to.Before(f.End) {
+			fmt.Fprintf(&b, "outpoint %.6f\n", to.Sub(f.Start).Seconds())
+		}
+	}
+	return b.String()
+}
+
+// FFMpegRecordExport export the wall-clock range from to of the recording in dir without re-encoding,
+// an output ending with .m3u8 is a HLS VOD package and the other outputs are a single file,
+// the cuts are on the keyframes before from and after to
+func FFMpegRecordExport(ctx Context, dir string, from, to time.Time, output string, opts ...RecordExportOptions) error {
+	if ctx == nil {
+		ctx = FFmpegContext()
+	}
+	ea := &RecordExportArgs{HLSTime: 4, SegmentFileName: "media-%05d.ts"}
+	for _, o := range opts {
+		o(ea)
+	}
+	idx, e := ReadRecordIndex(dir)
+	if e != nil {
+		return e
+	}
+	files := idx.Range(from, to)
+	if len(files) == 0 {
+		return ErrRecordRange
+	}
+	list, e := ioutil.TempFile(dir, ".export-*.ffconcat")
+	if e != nil {
+		return e
+	}
+	defer os.Remove(list.Name())
+	_, e = list.WriteString(concatList(files, from, to))
+	if err := list.Close(); e == nil {
+		e = err
+	}
+	if e != nil {
+		return e
+	}
+	if e := os.MkdirAll(filepath.Dir(output), os.ModePerm); e != nil {
+		return e
+	}
+	args := []string{"-y", "-f", "concat", "-safe", "0", "-i", list.Name(), "-map", "0", "-c", "copy"}
+	if strings.EqualFold(filepath.Ext(output), ".m3u8") {
+		args = append(args, "-f", "hls", "-hls_time", fmt.Sprint(ea.HLSTime), "-hls_playlist_type", "vod",
+			"-hls_segment_filename", filepath.Join(filepath.Dir(output), ea.SegmentFileName), output)
+	} else {
+		if ext := strings.ToLower(filepath.Ext(output)); ext == ".mp4" || ext == ".mov" {
+			args = append(args, "-movflags", "+faststart")
+		}
+		args = append(args, output)
+	}
+	ffmpeg := NewFFMpeg()
+	ffmpeg.Args = args
+	return ffmpegRun(ctx, ffmpeg)
+}
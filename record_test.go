@@ -0,0 +1,128 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRecordFFmpeg record the arguments, the segment muxer writes two files and the csv list,
+// the concat export touches the output
+const fakeRecordFFmpeg = fakeLog + `list=
+while [ $# -gt 1 ]; do
+	[ "$1" = "-segment_list" ] && list="$2"
+	shift
+done
+if [ -z "$list" ]; then
+	touch "$1"
+	exit 0
+fi
+dir=$(dirname "$1")
+printf a > "$dir/rec-0.ts"
+printf b > "$dir/rec-1.ts"
+printf '%s\n' 'rec-0.ts,1.500000,11.500000' 'rec-1.ts,11.500000,21.500000' > "$list"
+`
+
+// TestFFMpegRecord ...
+func TestFFMpegRecord(t *testing.T) {
+	dir, logFile, cleanup := testLogDir(t, "record")
+	defer cleanup()
+	defer fakeCommand(t, "ffmpeg", fakeRecordFFmpeg)()
+
+	out := filepath.Join(dir, "out")
+	r, e := FFMpegRecord(nil, "rtmp://127.0.0.1/live/test", RecordOutputOption(out), RecordSegmentOption(10*time.Second),
+		RecordRestartOption(0, 0))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e := r.Wait(); e != nil {
+		t.Fatal(e)
+	}
+	b, _ := ioutil.ReadFile(logFile)
+	if !strings.Contains(string(b), "-i rtmp://127.0.0.1/live/test -map 0 -c copy -f segment -segment_time 10 -segment_list "+out+"/.record-0.csv -segment_list_type csv -reset_timestamps 1 -strftime 1 "+out+"/record-%Y%m%d-%H%M%S-0.ts") {
+		t.Fatal(string(b))
+	}
+	idx, e := ReadRecordIndex(out)
+	if e != nil || len(idx.Files) != 2 {
+		t.Fatal(e, idx)
+	}
+	first, second := idx.Files[0], idx.Files[1]
+	if first.Name != "rec-0.ts" || first.End.Sub(first.Start) != 10*time.Second || !second.Start.Equal(first.End) {
+		t.Fatalf("%+v", idx.Files)
+	}
+	if _, e := os.Stat(filepath.Join(out, ".record-0.csv")); !os.IsNotExist(e) {
+		t.Fatal("segment list kept", e)
+	}
+
+	from := first.Start.Add(5 * time.Second)
+	to := second.Start.Add(2 * time.Second)
+	if files := idx.Range(from, to); len(files) != 2 {
+		t.Fatal(files)
+	}
+	if files := idx.Range(first.Start, first.End); len(files) != 1 {
+		t.Fatal(files)
+	}
+	if list := concatList(idx.Range(from, to), from, to); list != "ffconcat version 1.0\nfile 'rec-0.ts'\ninpoint 5.000000\nfile 'rec-1.ts'\noutpoint 2.000000\n" {
+		t.Fatal(list)
+	}
+	export := filepath.Join(dir, "export", "media.m3u8")
+	if e := FFMpegRecordExport(nil, out, from, to, export, RecordExportHLSOption(2, "seg-%03d.ts")); e != nil {
+		t.Fatal(e)
+	}
+	b, _ = ioutil.ReadFile(logFile)
+	if !strings.Contains(string(b), "-f concat -safe 0 -i "+out+"/.export-") ||
+		!strings.Contains(string(b), "-map 0 -c copy -f hls -hls_time 2 -hls_playlist_type vod -hls_segment_filename "+dir+"/export/seg-%03d.ts "+export) {
+		t.Fatal(string(b))
+	}
+	if e := FFMpegRecordExport(nil, out, second.End, second.End.Add(time.Hour), filepath.Join(dir, "a.mp4")); e != ErrRecordRange {
+		t.Fatal(e)
+	}
+}
+
+// fakeStopRecordFFmpeg write one file to the list and the file being written on SIGINT
+const fakeStopRecordFFmpeg = `while [ $# -gt 1 ]; do
+	[ "$1" = "-segment_list" ] && list="$2"
+	shift
+done
+trap 'sleep 0.2; echo "rec-1.ts,10.000000,13.000000" >> "$list"; exit 255' INT
+echo "rec-0.ts,0.000000,10.000000" > "$list"
+while :; do sleep 0.05; done
+`
+
+// TestRecorder_Stop ...
+func TestRecorder_Stop(t *testing.T) {
+	dir, e := ioutil.TempDir("", "record")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	defer fakeCommand(t, "ffmpeg", fakeStopRecordFFmpeg)()
+
+	ctx := FFmpegContext()
+	r, e := FFMpegRecord(ctx, "rtmp://127.0.0.1/live/test", RecordOutputOption(dir))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if name := r.Args.fileName(2); name != "record-%Y%m%d-%H%M%S-2.ts" {
+		t.Fatal(name)
+	}
+	for i := 0; len(r.Index().Files) == 0; i++ {
+		if i > 100 {
+			t.Fatal("no file indexed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if e := r.Stop(); e != nil {
+		t.Fatal(e)
+	}
+	if ctx.Context().Err() != nil {
+		t.Fatal("the context of the caller is canceled")
+	}
+	idx, e := ReadRecordIndex(dir)
+	if e != nil || len(idx.Files) != 2 || idx.Files[1].Name != "rec-1.ts" {
+		t.Fatal(e, idx)
+	}
+}
@@ -0,0 +1,189 @@
+package fftool
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/glvd/go-fftool/m3u8"
+	"golang.org/x/xerrors"
+)
+
+const variantVideo = "video"
+const groupAudio = "audio"
+
+// ErrRenditionLanguage ...
+var ErrRenditionLanguage = xerrors.New("no audio stream of the languages")
+
+// Rendition an alternative media of the master playlist
+type Rendition struct {
+	Type     string //AUDIO or SUBTITLES
+	Group    string
+	Name     string
+	Language string
+	Default  bool
+	Forced   bool
+	URI      string
+	Stream   *Stream //input stream
+	File     string  //input file when it is not a stream of the input
+	Codec    string  //output codec
+}
+
+// AudioRenditionsOption package every audio stream, or the streams of lang, as separate renditions.
+// The split fails with ErrRenditionLanguage when no stream has one of the languages
+func AudioRenditionsOption(lang ...string) SplitOptions {
+	return func(args *SplitArgs) {
+		args.multiAudio = true
+		args.audioLanguages = lang
+	}
+}
+
+// renditions returns the renditions of type t
+func (sa *SplitArgs) renditions(t string) []Rendition {
+	var r []Rendition
+	for _, v := range sa.Renditions {
+		if v.Type == t {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// audioRenditions add the audio streams as renditions, it fails when no stream has one of the languages
+func (sa *SplitArgs) audioRenditions(sel StreamSelector) error {
+	streams := sel.All(sa.StreamFormat, "audio")
+	if len(sa.audioLanguages) > 0 {
+		var selected []Stream
+		for _, lang := range sa.audioLanguages {
+			for _, s := range streams {
+				if strings.EqualFold(s.Tags.Language, lang) {
+					selected = append(selected, s)
+				}
+			}
+		}
+		if len(selected) == 0 {
+			return xerrors.Errorf("%s: %w", strings.Join(sa.audioLanguages, ","), ErrRenditionLanguage)
+		}
+		streams = selected
+	}
+	for i := range streams {
+		s := streams[i]
+		name := fmt.Sprintf("%s%d", groupAudio, i)
+		codec := "aac"
+		if s.CodecName == "aac" {
+			codec = "copy"
+		}
+		sa.Renditions = append(sa.Renditions, Rendition{
+			Type:     "AUDIO",
+			Group:    groupAudio,
+			Name:     renditionName(&s, i),
+			Language: renditionLanguage(&s),
+			Default:  i == 0,
+			URI:      variantName(name, sa.M3U8),
+			Stream:   &s,
+			Codec:    codec,
+		})
+	}
+	return nil
+}
+
+func renditionName(s *Stream, i int) string {
+	if s.Tags.Title != "" {
+		return s.Tags.Title
+	}
+	if l := renditionLanguage(s); l != "" {
+		return l
+	}
+	return fmt.Sprintf("%s %d", s.CodecType, i+1)
+}
+
+func renditionLanguage(s *Stream) string {
+	if s.Tags.Language == "und" {
+		return ""
+	}
+	return s.Tags.Language
+}
+
+// variantName returns the file name of a variant stream
+func variantName(name, file string) string {
+	return name + "_" + file
+}
+
+// audioArgs returns the map and codec arguments of the audio renditions
+func (sa *SplitArgs) audioArgs() string {
+	var args []string
+	for i, r := range sa.renditions("AUDIO") {
+		args = append(args, fmt.Sprintf("-map 0:%d -c:a:%d %s", r.Stream.Index, i, r.Codec))
+	}
+	return strings.Join(args, " ")
+}
+
+// varStreamMap returns the -var_stream_map value, the video and every audio are separate variants
+func (sa *SplitArgs) varStreamMap() string {
+	maps := []string{fmt.Sprintf("v:0,agroup:%s,name:%s", groupAudio, variantVideo)}
+	for i := range sa.renditions("AUDIO") {
+		maps = append(maps, fmt.Sprintf("a:%d,agroup:%s,name:%s%d", i, groupAudio, groupAudio, i))
+	}
+	return strings.Join(maps, " ")
+}
+
+// writeMaster write the master playlist referencing the video variant, the renditions and the I-frame playlist
+func (sa *SplitArgs) writeMaster(dir string) error {
+	master := &m3u8.MasterPlaylist{Version: 3}
+	variant := &m3u8.Variant{
+		Resolution: sa.resolution(),
+		URI:        variantName(variantVideo, sa.M3U8),
+	}
+	bandwidth := sa.videoBitRate()
+	audioRate := int64(0)
+	for _, r := range sa.Renditions {
+		master.Media = append(master.Media, &m3u8.Media{
+			Type:       r.Type,
+			GroupID:    r.Group,
+			Name:       r.Name,
+			Language:   r.Language,
+			Default:    r.Default,
+			AutoSelect: true,
+			Forced:     r.Type == "SUBTITLES" && r.Forced,
+			URI:        r.URI,
+		})
+		switch r.Type {
+		case "AUDIO":
+			variant.Audio = r.Group
+			if b := audioBitRate(r.Stream, r.Codec); b > audioRate {
+				audioRate = b
+			}
+		case "SUBTITLES":
+			variant.Subtitles = r.Group
+		}
+	}
+	if len(sa.renditions("AUDIO")) == 0 {
+		//the audio is muxed into the video variant
+		audioRate = audioBitRate(sa.audioStream(), sa.Audio)
+	}
+	variant.Bandwidth = bandwidth + audioRate
+	master.Variants = append(master.Variants, variant)
+	if sa.iframes {
+		master.IFrames = append(master.IFrames, &m3u8.Variant{
+			Bandwidth:  sa.iframeBandwidth,
+			Resolution: sa.resolution(),
+			URI:        sa.iframePlaylist(),
+		})
+	}
+	return m3u8.WriteFile(filepath.Join(dir, sa.M3U8), master)
+}
+
+// resolution returns the output WIDTHxHEIGHT
+func (sa *SplitArgs) resolution() string {
+	video := sa.videoStream()
+	if video == nil || video.Width == nil || video.Height == nil || *video.Height == 0 {
+		return ""
+	}
+	w, h := *video.Width, *video.Height
+	if sa.Scale != 0 {
+		//scale=-2:h keeps the aspect with an even width
+		w = (w*sa.Scale/h + 1) / 2 * 2
+		h = sa.Scale
+	}
+	return fmt.Sprintf("%dx%d", w, h)
+}
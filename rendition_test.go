@@ -0,0 +1,61 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+// TestSplitArgs_AudioRenditions ...
+func TestSplitArgs_AudioRenditions(t *testing.T) {
+	sf := testSelectFormat(t)
+	sa := &SplitArgs{StreamFormat: sf, M3U8: "media.m3u8", VideoStream: sf.Video(), Video: "copy"}
+	AudioRenditionsOption("jpn", "eng")(sa)
+	if e := sa.audioRenditions(DefaultStreamSelector()); e != nil {
+		t.Fatal(e)
+	}
+	audios := sa.renditions("AUDIO")
+	if len(audios) != 3 {
+		t.Fatalf("%+v", audios)
+	}
+	if audios[0].Stream.Index != 3 || !audios[0].Default || audios[0].URI != "audio0_media.m3u8" || audios[0].Codec != "copy" {
+		t.Fatalf("%+v", audios[0])
+	}
+	if audios[1].Stream.Index != 4 || audios[1].Codec != "aac" || audios[2].Language != "eng" {
+		t.Fatalf("%+v", audios[1:])
+	}
+	if e := (&SplitArgs{StreamFormat: sf, audioLanguages: []string{"fra"}}).audioRenditions(DefaultStreamSelector()); !xerrors.Is(e, ErrRenditionLanguage) {
+		t.Fatal(e)
+	}
+	if m := sa.varStreamMap(); m != "v:0,agroup:audio,name:video a:0,agroup:audio,name:audio0 a:1,agroup:audio,name:audio1 a:2,agroup:audio,name:audio2" {
+		t.Fatal(m)
+	}
+	if a := sa.audioArgs(); a != "-map 0:3 -c:a:0 copy -map 0:4 -c:a:1 aac -map 0:2 -c:a:2 copy" {
+		t.Fatal(a)
+	}
+
+	dir, e := ioutil.TempDir("", "fftool")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	if e := sa.writeMaster(dir); e != nil {
+		t.Fatal(e)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "media.m3u8"))
+	master := string(b)
+	for _, want := range []string{
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="jpn",LANGUAGE="jpn",DEFAULT=YES,AUTOSELECT=YES,URI="audio0_media.m3u8"`,
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="eng",LANGUAGE="eng",DEFAULT=NO,AUTOSELECT=YES,URI="audio2_media.m3u8"`,
+		`AUDIO="audio"`,
+		"\nvideo_media.m3u8\n",
+	} {
+		if !strings.Contains(master, want) {
+			t.Errorf("missing %s in\n%s", want, master)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package fftool
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+	"golang.org/x/xerrors"
+)
+
+// ErrTokenExpired ...
+var ErrTokenExpired = xerrors.New("token expired")
+
+// ErrTokenInvalid ...
+var ErrTokenInvalid = xerrors.New("token invalid")
+
+// Rewriter rewrite the URIs of a playlist package for a CDN,
+// relative URIs are prefixed with BaseURL and signed with Secret
+type Rewriter struct {
+	BaseURL     string        //prefix of the segments, empty keeps them relative
+	KeyURL      string        //prefix of the key URIs, empty uses BaseURL. When set every key URI is moved to it
+	Secret      []byte        //HMAC secret, empty disables signing
+	Expire      time.Duration //lifetime of a token
+	Playlists   bool          //rewrite the playlist URIs of a master playlist too
+	TokenParam  string
+	ExpireParam string
+	now         func() time.Time
+}
+
+// NewRewriter ...
+func NewRewriter(base string, secret []byte, expire time.Duration) *Rewriter {
+	return &Rewriter{
+		BaseURL:     base,
+		Secret:      secret,
+		Expire:      expire,
+		TokenParam:  "token",
+		ExpireParam: "expires",
+		now:         time.Now,
+	}
+}
+
+func (rw *Rewriter) time() time.Time {
+	if rw.now == nil {
+		return time.Now()
+	}
+	return rw.now()
+}
+
+// Sign returns the token of the escaped URL path p valid until expires,
+// without BaseURL p is the path relative to the package root
+func (rw *Rewriter) Sign(p string, expires int64) string {
+	mac := hmac.New(sha256.New, rw.Secret)
+	mac.Write([]byte(p + "?" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify check the token and the expiry in the query of a signed URL
+func (rw *Rewriter) Verify(u *url.URL) error {
+	q := u.Query()
+	expires, e := strconv.ParseInt(q.Get(rw.ExpireParam), 10, 64)
+	if e != nil {
+		return ErrTokenInvalid
+	}
+	if !hmac.Equal([]byte(q.Get(rw.TokenParam)), []byte(rw.Sign(u.EscapedPath(), expires))) {
+		return ErrTokenInvalid
+	}
+	if rw.time().Unix() > expires {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+// target returns the URL of the package path rel below base
+func target(base string, rel string) string {
+	if base == "" {
+		return rel
+	}
+	return strings.TrimSuffix(base, "/") + "/" + rel
+}
+
+// rewriteURI returns the rewritten uri referenced by the playlist name, absolute URIs are kept
+// unless move is set, then their path is moved below base
+func (rw *Rewriter) rewriteURI(base string, name string, uri string, move bool) (string, error) {
+	if uri == "" {
+		return uri, nil
+	}
+	ref, e := url.Parse(uri)
+	if e != nil {
+		return "", e
+	}
+	rel := path.Join(path.Dir(name), ref.Path)
+	if ref.IsAbs() || strings.HasPrefix(ref.Path, "/") {
+		//an inline data key has no location to move
+		if !move || ref.Scheme == "data" {
+			return uri, nil
+		}
+		rel = strings.TrimPrefix(path.Clean("/"+ref.Path), "/")
+	}
+	u, e := url.Parse(target(base, rel))
+	if e != nil {
+		return "", e
+	}
+	q := u.Query()
+	for k, v := range ref.Query() {
+		q[k] = v
+	}
+	if len(rw.Secret) > 0 {
+		expires := rw.time().Add(rw.Expire).Unix()
+		q.Set(rw.ExpireParam, strconv.FormatInt(expires, 10))
+		q.Set(rw.TokenParam, rw.Sign(u.EscapedPath(), expires))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Rewrite rewrite the URIs of p in place, name is the path of p relative to the package root
+func (rw *Rewriter) Rewrite(p m3u8.Playlist, name string) (e error) {
+	keyBase := rw.KeyURL
+	if keyBase == "" {
+		keyBase = rw.BaseURL
+	}
+	switch v := p.(type) {
+	case *m3u8.MediaPlaylist:
+		for _, s := range v.Segments {
+			if s.URI, e = rw.rewriteURI(rw.BaseURL, name, s.URI, false); e != nil {
+				return e
+			}
+			if s.Map != nil {
+				if s.Map.URI, e = rw.rewriteURI(rw.BaseURL, name, s.Map.URI, false); e != nil {
+					return e
+				}
+			}
+			if s.Key != nil {
+				if s.Key.URI, e = rw.rewriteURI(keyBase, name, s.Key.URI, rw.KeyURL != ""); e != nil {
+					return e
+				}
+			}
+		}
+	case *m3u8.MasterPlaylist:
+		if !rw.Playlists {
+			return nil
+		}
+		for _, m := range v.Media {
+			if m.URI, e = rw.rewriteURI(rw.BaseURL, name, m.URI, false); e != nil {
+				return e
+			}
+		}
+		for _, variant := range v.Variants {
+			if variant.URI, e = rw.rewriteURI(rw.BaseURL, name, variant.URI, false); e != nil {
+				return e
+			}
+		}
+		for _, variant := range v.IFrames {
+			if variant.URI, e = rw.rewriteURI(rw.BaseURL, name, variant.URI, false); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// RewriteDir write the rewritten copies of the playlists in src to dst, the other files are not copied
+func (rw *Rewriter) RewriteDir(src string, dst string) error {
+	return filepath.Walk(src, func(file string, info os.FileInfo, e error) error {
+		if e != nil {
+			return e
+		}
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(file), ".m3u8") {
+			return nil
+		}
+		rel, e := filepath.Rel(src, file)
+		if e != nil {
+			return e
+		}
+		p, e := m3u8.ReadFile(file)
+		if e != nil {
+			return e
+		}
+		if e := rw.Rewrite(p, filepath.ToSlash(rel)); e != nil {
+			return e
+		}
+		out := filepath.Join(dst, rel)
+		if e := os.MkdirAll(filepath.Dir(out), os.ModePerm); e != nil {
+			return e
+		}
+		return m3u8.WriteFile(out, p)
+	})
+}
+
+// verifyRequest check the token of the request for the package path name, the token
+// is signed for the URL of name below BaseURL or below KeyURL
+func (rw *Rewriter) verifyRequest(name string, query string) error {
+	e := ErrTokenInvalid
+	for _, base := range []string{rw.BaseURL, rw.KeyURL} {
+		u, err := url.Parse(target(base, strings.TrimPrefix(name, "/")))
+		if err != nil {
+			continue
+		}
+		u.RawQuery = query
+		if e = rw.Verify(u); e != ErrTokenInvalid {
+			return e
+		}
+	}
+	return e
+}
+
+// fileOnly a file system without directories, so there is no directory listing
+type fileOnly struct {
+	http.FileSystem
+}
+
+// Open ...
+func (fs fileOnly) Open(name string) (http.File, error) {
+	f, e := fs.FileSystem.Open(name)
+	if e != nil {
+		return nil, e
+	}
+	if info, e := f.Stat(); e != nil || info.IsDir() {
+		_ = f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
+// Handler serve the package in root, the playlists are rewritten for every request
+// so every response carries fresh tokens, the other files are served after their token
+// is verified when Secret is set
+func (rw *Rewriter) Handler(root string) http.Handler {
+	dir := fileOnly{http.Dir(root)}
+	files := http.FileServer(dir)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if !strings.EqualFold(path.Ext(name), ".m3u8") {
+			if len(rw.Secret) > 0 {
+				if e := rw.verifyRequest(name, r.URL.RawQuery); e != nil {
+					http.Error(w, e.Error(), http.StatusForbidden)
+					return
+				}
+			}
+			files.ServeHTTP(w, r)
+			return
+		}
+		f, e := dir.Open(name)
+		if e != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		p, e := m3u8.Parse(f)
+		if e == nil {
+			e = rw.Rewrite(p, strings.TrimPrefix(name, "/"))
+		}
+		var buf bytes.Buffer
+		if e == nil {
+			e = p.Encode(&buf)
+		}
+		if e != nil {
+			log.With("path", name).Error(e)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(buf.Bytes())
+	})
+}
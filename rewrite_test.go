@@ -0,0 +1,143 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+)
+
+func testRewriter() *Rewriter {
+	rw := NewRewriter("https://cdn.example.com/vod/abc", []byte("secret"), time.Hour)
+	rw.KeyURL = "https://keys.example.com"
+	rw.now = func() time.Time { return time.Unix(1000, 0) }
+	return rw
+}
+
+// TestRewriter_Rewrite ...
+func TestRewriter_Rewrite(t *testing.T) {
+	rw := testRewriter()
+	p, e := m3u8.ParseMedia(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXTINF:10,\nmedia-00000.ts?a=1\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"https://old.example.com/keys/k.bin?id=2\"\n#EXTINF:10,\nhttp://other/media-00001.ts\n#EXT-X-ENDLIST\n"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e := rw.Rewrite(p, "sub/video.m3u8"); e != nil {
+		t.Fatal(e)
+	}
+	if p.Segments[1].URI != "http://other/media-00001.ts" {
+		t.Fatal(p.Segments[1].URI)
+	}
+	u, e := url.Parse(p.Segments[0].URI)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if u.Host != "cdn.example.com" || u.Path != "/vod/abc/sub/media-00000.ts" || u.Query().Get("a") != "1" || u.Query().Get("expires") != "4600" {
+		t.Fatal(u)
+	}
+	if e := rw.Verify(u); e != nil {
+		t.Fatal(e)
+	}
+	if !strings.HasPrefix(p.Segments[0].Key.URI, "https://keys.example.com/sub/key.bin?") {
+		t.Fatal(p.Segments[0].Key.URI)
+	}
+	//an absolute key URI is moved to the key server too
+	if !strings.HasPrefix(p.Segments[1].Key.URI, "https://keys.example.com/keys/k.bin?") || !strings.Contains(p.Segments[1].Key.URI, "id=2") {
+		t.Fatal(p.Segments[1].Key.URI)
+	}
+
+	tampered := *u
+	tampered.Path = "/vod/abc/sub/media-00001.ts"
+	if e := rw.Verify(&tampered); e != ErrTokenInvalid {
+		t.Fatal(e)
+	}
+	rw.now = func() time.Time { return time.Unix(5000, 0) }
+	if e := rw.Verify(u); e != ErrTokenExpired {
+		t.Fatal(e)
+	}
+
+	master, e := m3u8.ParseMaster(strings.NewReader("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nvideo.m3u8\n"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _ = rw.Rewrite(master, "media.m3u8"); master.Variants[0].URI != "video.m3u8" {
+		t.Fatal("master rewritten without Playlists")
+	}
+}
+
+// TestRewriter_Handler ...
+func TestRewriter_Handler(t *testing.T) {
+	dir, e := ioutil.TempDir("", "rewrite")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	_ = ioutil.WriteFile(filepath.Join(dir, "media.m3u8"), []byte("#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nmedia-00000.ts\n#EXT-X-ENDLIST\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "media-00000.ts"), []byte("ts"), 0644)
+	rw := testRewriter()
+
+	h := rw.Handler(dir)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/media.m3u8", nil))
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/vnd.apple.mpegurl" ||
+		!strings.Contains(w.Body.String(), "https://cdn.example.com/vod/abc/media-00000.ts?expires=4600&token=") {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/../media.m3u8", nil))
+	if w.Code != 200 {
+		t.Fatal(w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/missing.m3u8", nil))
+	if w.Code != 404 {
+		t.Fatal(w.Code)
+	}
+
+	//the segments are only served with a valid token
+	signed := &url.URL{}
+	body := httptest.NewRecorder()
+	h.ServeHTTP(body, httptest.NewRequest("GET", "/media.m3u8", nil))
+	for _, line := range strings.Split(body.Body.String(), "\n") {
+		if strings.HasPrefix(line, "https://") {
+			signed, _ = url.Parse(line)
+		}
+	}
+	for uri, code := range map[string]int{
+		"/media-00000.ts?" + signed.RawQuery:    200,
+		"/media-00000.ts":                       403,
+		"/media-00000.ts?expires=4600&token=00": 403,
+		"/?" + signed.RawQuery:                  403,
+	} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", uri, nil))
+		if w.Code != code {
+			t.Fatal(uri, w.Code)
+		}
+	}
+	rw.Secret = nil
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 404 {
+		t.Fatal("directory listed", w.Code)
+	}
+	rw.Secret = []byte("secret")
+
+	out := filepath.Join(dir, "out")
+	if e := rw.RewriteDir(dir, out); e != nil {
+		t.Fatal(e)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(out, "media.m3u8"))
+	if !strings.Contains(string(b), "https://cdn.example.com/vod/abc/media-00000.ts?") {
+		t.Fatal(string(b))
+	}
+	if _, e := os.Stat(filepath.Join(out, "media-00000.ts")); !os.IsNotExist(e) {
+		t.Fatal("segment copied")
+	}
+}
@@ -0,0 +1,178 @@
+package fftool
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const safeInputOptions = "-protocol_whitelist file"
+
+// ErrUnsafeInput ...
+var ErrUnsafeInput = xerrors.New("unsafe input")
+
+// ErrUnsafeOutput ...
+var ErrUnsafeOutput = xerrors.New("unsafe output")
+
+var urlScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.\-]+:`)
+
+// SafeOption only allow local files as input and outputs under root
+func SafeOption(root string) SplitOptions {
+	return func(args *SplitArgs) {
+		args.Safe = true
+		args.Root = root
+	}
+}
+
+// TrustProbeOption allow the probe of ProbeInfoOption in safe mode, the probe must only open local files
+func TrustProbeOption(b bool) SplitOptions {
+	return func(args *SplitArgs) {
+		args.trustProbe = b
+	}
+}
+
+// checkSafeInput ...
+func checkSafeInput(file string) error {
+	if strings.HasPrefix(file, "-") {
+		return xerrors.Errorf("%s:input cannot start with '-': %w", file, ErrUnsafeInput)
+	}
+	if isURL(file) {
+		return xerrors.Errorf("%s:input must be a local file: %w", file, ErrUnsafeInput)
+	}
+	info, e := os.Stat(file)
+	if e != nil {
+		return e
+	}
+	if !info.Mode().IsRegular() {
+		return xerrors.Errorf("%s:input must be a regular file: %w", file, ErrUnsafeInput)
+	}
+	f, e := os.Open(file)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+	return checkSafeContent(f, filepath.Dir(file))
+}
+
+// checkSafeContent reject playlists and concat lists which reference urls or files outside dir
+func checkSafeContent(r io.Reader, dir string) error {
+	reader := bufio.NewReader(r)
+	head, _ := reader.Peek(32)
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	var refs []string
+	switch {
+	case bytes.HasPrefix(head, []byte("#EXTM3U")):
+		refs = playlistRefs(reader)
+	case bytes.HasPrefix(head, []byte("ffconcat")):
+		refs = concatRefs(reader)
+	default:
+		return nil
+	}
+	for _, ref := range refs {
+		if isURL(ref) {
+			return xerrors.Errorf("%s:reference to url: %w", ref, ErrUnsafeInput)
+		}
+		if filepath.IsAbs(ref) || !withinRoot(dir, filepath.Join(dir, ref)) {
+			return xerrors.Errorf("%s:reference outside input directory: %w", ref, ErrUnsafeInput)
+		}
+	}
+	return nil
+}
+
+var uriAttr = regexp.MustCompile(`URI="([^"]*)"`)
+
+func playlistRefs(r io.Reader) (refs []string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			for _, m := range uriAttr.FindAllStringSubmatch(line, -1) {
+				refs = append(refs, m[1])
+			}
+			continue
+		}
+		refs = append(refs, line)
+	}
+	return refs
+}
+
+func concatRefs(r io.Reader) (refs []string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "file" {
+			continue
+		}
+		ref := strings.Join(fields[1:], " ")
+		refs = append(refs, strings.Trim(ref, `'"`))
+	}
+	return refs
+}
+
+func isURL(s string) bool {
+	//windows drive letters like C: are not urls
+	return urlScheme.MatchString(s)
+}
+
+// checkSafeOutput ...
+func checkSafeOutput(root string, paths ...string) error {
+	if root == "" {
+		return xerrors.Errorf("safe mode need a root directory: %w", ErrUnsafeOutput)
+	}
+	for _, p := range paths {
+		if !withinRoot(root, p) {
+			return xerrors.Errorf("%s:output outside %s: %w", p, root, ErrUnsafeOutput)
+		}
+	}
+	return nil
+}
+
+// withinRoot check path is root or under root after resolving symlinks
+func withinRoot(root, path string) bool {
+	root, e := resolvePath(root)
+	if e != nil {
+		return false
+	}
+	path, e = resolvePath(path)
+	if e != nil {
+		return false
+	}
+	rel, e := filepath.Rel(root, path)
+	if e != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvePath returns the absolute path with symlinks resolved for the existing part
+func resolvePath(path string) (string, error) {
+	path, e := filepath.Abs(path)
+	if e != nil {
+		return "", e
+	}
+	rest := ""
+	for {
+		resolved, e := filepath.EvalSymlinks(path)
+		if e == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(e) {
+			return "", e
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest), nil
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
@@ -0,0 +1,102 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+// TestCheckSafeInput ...
+func TestCheckSafeInput(t *testing.T) {
+	dir, e := ioutil.TempDir("", "fftool")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if e := ioutil.WriteFile(p, []byte(content), 0644); e != nil {
+			t.Fatal(e)
+		}
+		return p
+	}
+	safe := []string{
+		write("video.mp4", "\x00\x00\x00\x18ftypmp42"),
+		write("local.m3u8", "#EXTM3U\n#EXTINF:10,\nmedia-00000.ts\n"),
+		write("local.txt", "ffconcat version 1.0\nfile 'a.mp4'\n"),
+	}
+	for _, f := range safe {
+		if e := checkSafeInput(f); e != nil {
+			t.Errorf("%s:%v", f, e)
+		}
+	}
+	unsafe := []string{
+		"-i",
+		"http://127.0.0.1/video.mp4",
+		"concat:a.mp4|b.mp4",
+		write("remote.m3u8", "#EXTM3U\n#EXTINF:10,\nhttp://169.254.169.254/latest\n"),
+		write("key.m3u8", "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"https://example.com/key\"\n#EXTINF:10,\na.ts\n"),
+		write("escape.m3u8", "#EXTM3U\n#EXTINF:10,\n../../etc/passwd\n"),
+		write("abs.txt", "ffconcat version 1.0\nfile '/etc/passwd'\n"),
+	}
+	for _, f := range unsafe {
+		if e := checkSafeInput(f); !xerrors.Is(e, ErrUnsafeInput) {
+			t.Errorf("%s:want unsafe input,got %v", f, e)
+		}
+	}
+}
+
+// TestCheckSafeOutput ...
+func TestCheckSafeOutput(t *testing.T) {
+	root, e := ioutil.TempDir("", "fftool")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(root)
+	if e := checkSafeOutput(root, filepath.Join(root, "a", "media.m3u8")); e != nil {
+		t.Error(e)
+	}
+	if e := checkSafeOutput(root, filepath.Join(root, "..", "media.m3u8")); !xerrors.Is(e, ErrUnsafeOutput) {
+		t.Error("want unsafe output", e)
+	}
+	if e := os.Symlink(os.TempDir(), filepath.Join(root, "link")); e != nil {
+		t.Skip(e)
+	}
+	if e := checkSafeOutput(root, filepath.Join(root, "link", "media.m3u8")); !xerrors.Is(e, ErrUnsafeOutput) {
+		t.Error("want unsafe output", e)
+	}
+}
+
+// TestPrepareSplit_SafeProbe ...
+func TestPrepareSplit_SafeProbe(t *testing.T) {
+	dir, logFile, cleanup := testLogDir(t, "fftool")
+	defer cleanup()
+	defer fakeCommand(t, "ffprobe", fakeLog+"exit 1\n")()
+	file := filepath.Join(dir, "video.mp4")
+	_ = ioutil.WriteFile(file, []byte("\x00\x00\x00\x18ftypmp42"), 0644)
+
+	called := false
+	probe := func(string) (*StreamFormat, error) {
+		called = true
+		return nil, xerrors.New("probed")
+	}
+	if _, e := prepareSplit(file, SafeOption(dir), ProbeInfoOption(probe)); !xerrors.Is(e, ErrUnsafeInput) || called {
+		t.Fatal(e, called)
+	}
+	if _, e := prepareSplit(file, SafeOption(dir), ProbeInfoOption(probe), TrustProbeOption(true)); e == nil || !called {
+		t.Fatal(e, called)
+	}
+
+	//the default probe is chosen from the safe mode
+	if _, e := prepareSplit(file, SafeOption(dir), probeOption()); e == nil {
+		t.Fatal("want probe error")
+	}
+	b, _ := ioutil.ReadFile(logFile)
+	if !strings.Contains(string(b), "-protocol_whitelist file file:"+file) {
+		t.Fatal(string(b))
+	}
+}
@@ -0,0 +1,112 @@
+package fftool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StreamSelector rules to choose a stream when the input has several
+type StreamSelector struct {
+	AttachedPic bool     //allow cover images stored as video streams
+	Default     bool     //prefer the default disposition
+	Languages   []string //preferred languages in order, like "jpn","eng"
+	Channels    bool     //prefer the highest channel count
+}
+
+// DefaultStreamSelector ...
+func DefaultStreamSelector() StreamSelector {
+	return StreamSelector{
+		Default:  true,
+		Channels: true,
+	}
+}
+
+// Video ...
+func (sel StreamSelector) Video(f *StreamFormat) *Stream {
+	return sel.Select(f, "video")
+}
+
+// Audio ...
+func (sel StreamSelector) Audio(f *StreamFormat) *Stream {
+	return sel.Select(f, "audio")
+}
+
+// Select returns the best stream of codecType, nil when there is no match
+func (sel StreamSelector) Select(f *StreamFormat, codecType string) *Stream {
+	var best *Stream
+	for i := range f.Streams {
+		s := &f.Streams[i]
+		if s.CodecType != codecType || (!sel.AttachedPic && s.IsAttachedPic()) {
+			continue
+		}
+		if best == nil || sel.less(s, best) {
+			best = s
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	s := *best
+	return &s
+}
+
+// All returns all streams of codecType in preference order
+func (sel StreamSelector) All(f *StreamFormat, codecType string) []Stream {
+	var streams []Stream
+	for _, s := range f.Streams {
+		if s.CodecType != codecType || (!sel.AttachedPic && s.IsAttachedPic()) {
+			continue
+		}
+		idx := len(streams)
+		for idx > 0 && sel.less(&s, &streams[idx-1]) {
+			idx--
+		}
+		streams = append(streams, Stream{})
+		copy(streams[idx+1:], streams[idx:])
+		streams[idx] = s
+	}
+	return streams
+}
+
+// less reports whether a is preferred over b
+func (sel StreamSelector) less(a, b *Stream) bool {
+	if la, lb := sel.languageRank(a), sel.languageRank(b); la != lb {
+		return la < lb
+	}
+	if sel.Default && a.IsDefault() != b.IsDefault() {
+		return a.IsDefault()
+	}
+	if sel.Channels {
+		if ca, cb := channels(a), channels(b); ca != cb {
+			return ca > cb
+		}
+	}
+	return a.Index < b.Index
+}
+
+func (sel StreamSelector) languageRank(s *Stream) int {
+	for i, l := range sel.Languages {
+		if strings.EqualFold(l, s.Tags.Language) {
+			return i
+		}
+	}
+	return len(sel.Languages)
+}
+
+func channels(s *Stream) int64 {
+	if s.Channels == nil {
+		return 0
+	}
+	return *s.Channels
+}
+
+// mapArgs ...
+func mapArgs(streams ...*Stream) string {
+	var maps []string
+	for _, s := range streams {
+		if s != nil {
+			maps = append(maps, fmt.Sprintf("-map 0:%d", s.Index))
+		}
+	}
+	return strings.Join(maps, " ")
+}
@@ -0,0 +1,49 @@
+package fftool
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func testSelectFormat(t *testing.T) *StreamFormat {
+	sf := StreamFormat{}
+	e := json.Unmarshal([]byte(`{"streams": [
+		{"index": 0, "codec_type": "video", "codec_name": "mjpeg", "disposition": {"attached_pic": 1}},
+		{"index": 1, "codec_type": "video", "codec_name": "h264", "disposition": {"default": 1}},
+		{"index": 2, "codec_type": "audio", "codec_name": "aac", "channels": 2, "tags": {"language": "eng"}},
+		{"index": 3, "codec_type": "audio", "codec_name": "aac", "channels": 2, "disposition": {"default": 1}, "tags": {"language": "jpn"}},
+		{"index": 4, "codec_type": "audio", "codec_name": "ac3", "channels": 6, "tags": {"language": "jpn"}},
+		{"index": 5, "codec_type": "audio", "codec_name": "aac", "channels": 6, "tags": {"language": "chi"}}
+	], "format": {"filename": "video.mp4"}}`), &sf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return &sf
+}
+
+// TestStreamSelector ...
+func TestStreamSelector(t *testing.T) {
+	sf := testSelectFormat(t)
+	if v := sf.Video(); v == nil || v.Index != 1 {
+		t.Fatal("cover image selected", v)
+	}
+	if a := sf.Audio(); a == nil || a.Index != 3 {
+		t.Fatal("default audio not selected", a)
+	}
+	sel := StreamSelector{Languages: []string{"ENG"}}
+	if a := sel.Audio(sf); a.Index != 2 {
+		t.Fatal(a.Index)
+	}
+	sel = StreamSelector{Languages: []string{"jpn"}, Channels: true}
+	if a := sel.Audio(sf); a.Index != 4 {
+		t.Fatal(a.Index)
+	}
+	sel = StreamSelector{Channels: true}
+	all := sel.All(sf, "audio")
+	if len(all) != 4 || all[0].Index != 4 || all[1].Index != 5 || all[2].Index != 2 {
+		t.Fatal(all)
+	}
+	if m := mapArgs(sf.Video(), sf.Audio()); m != "-map 0:1 -map 0:3" {
+		t.Fatal(m)
+	}
+}
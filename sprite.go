@@ -0,0 +1,239 @@
+package fftool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// SpriteArgs ...
+type SpriteArgs struct {
+	Interval     time.Duration
+	Columns      int
+	Rows         int
+	Width        int64 //tile width
+	Height       int64 //tile height, 0 keeps the aspect of the video
+	Format       string
+	Output       string
+	Name         string //sprite image prefix, the track is Name.vtt
+	StreamFormat *StreamFormat
+	Safe         bool
+	Root         string
+	Limits       *Limits
+}
+
+// SpriteOptions ...
+type SpriteOptions func(args *SpriteArgs)
+
+// SpriteIntervalOption take a tile every d
+func SpriteIntervalOption(d time.Duration) SpriteOptions {
+	return func(args *SpriteArgs) {
+		args.Interval = d
+	}
+}
+
+// SpriteGridOption set the tiles of every sprite image
+func SpriteGridOption(columns, rows int) SpriteOptions {
+	return func(args *SpriteArgs) {
+		args.Columns = columns
+		args.Rows = rows
+	}
+}
+
+// SpriteSizeOption set the tile size, a zero height keeps the aspect
+func SpriteSizeOption(w, h int64) SpriteOptions {
+	return func(args *SpriteArgs) {
+		args.Width = w
+		args.Height = h
+	}
+}
+
+// SpriteFormatOption set the image format: jpg, png or webp
+func SpriteFormatOption(f string) SpriteOptions {
+	return func(args *SpriteArgs) {
+		args.Format = f
+	}
+}
+
+// SpriteOutputOption set the output directory and the file name prefix
+func SpriteOutputOption(dir string, name string) SpriteOptions {
+	return func(args *SpriteArgs) {
+		args.Output = dir
+		args.Name = name
+	}
+}
+
+// SpriteStreamFormatOption use the probed info instead of running ffprobe
+func SpriteStreamFormatOption(sf *StreamFormat) SpriteOptions {
+	return func(args *SpriteArgs) {
+		args.StreamFormat = sf
+	}
+}
+
+// SpriteSafeOption only read a local file and write below root
+func SpriteSafeOption(root string) SpriteOptions {
+	return func(args *SpriteArgs) {
+		args.Safe = true
+		args.Root = root
+	}
+}
+
+func newSpriteArgs(opts ...SpriteOptions) *SpriteArgs {
+	sa := &SpriteArgs{
+		Interval: 10 * time.Second,
+		Columns:  5,
+		Rows:     5,
+		Width:    160,
+		Format:   "jpg",
+		Output:   ".",
+		Name:     "sprite",
+	}
+	for _, o := range opts {
+		o(sa)
+	}
+	return sa
+}
+
+// FFMpegSprites write the tiled preview images of file and a WebVTT track
+// whose cues point at the tiles with #xywh= fragments, returns the track and the images
+func FFMpegSprites(ctx Context, file string, opts ...SpriteOptions) (vtt string, images []string, e error) {
+	if ctx == nil {
+		ctx = FFmpegContext()
+	}
+	sp := newSpriteArgs(opts...)
+	if sp.Safe {
+		if e := checkSafeInput(file); e != nil {
+			return "", nil, e
+		}
+	}
+	return sp.run(ctx, file)
+}
+
+// run write the sprites, the input is already checked
+func (sp *SpriteArgs) run(ctx Context, file string) (string, []string, error) {
+	if sp.Interval <= 0 || sp.Columns <= 0 || sp.Rows <= 0 || sp.Width <= 0 {
+		return "", nil, xerrors.Errorf("wrong sprite settings(interval:%s,grid:%dx%d,width:%d)", sp.Interval, sp.Columns, sp.Rows, sp.Width)
+	}
+	ext, codec, e := thumbnailCodec(sp.Format)
+	if e != nil {
+		return "", nil, e
+	}
+	output, e := filepath.Abs(sp.Output)
+	if e != nil {
+		return "", nil, e
+	}
+	if sp.StreamFormat == nil {
+		probe := FFProbeStreamFormat
+		if sp.Safe {
+			probe = FFProbeStreamFormatSafe
+		}
+		if sp.StreamFormat, e = probe(file); e != nil {
+			return "", nil, e
+		}
+	}
+	duration, e := sp.StreamFormat.Format.DurationValue()
+	if e != nil {
+		return "", nil, e
+	}
+	height, e := sp.tileHeight()
+	if e != nil {
+		return "", nil, e
+	}
+	pattern := filepath.Join(output, sp.Name+"-%05d"+ext)
+	vtt := filepath.Join(output, sp.Name+".vtt")
+	if sp.Safe {
+		if e := checkSafeOutput(sp.Root, output, pattern, vtt); e != nil {
+			return "", nil, e
+		}
+		file = "file:" + file
+	}
+	if e := os.MkdirAll(output, os.ModePerm); e != nil {
+		return "", nil, e
+	}
+
+	ta := &ThumbnailArgs{Safe: sp.Safe, Limits: sp.Limits}
+	filter := fmt.Sprintf("fps=1/%g,scale=%d:%d,tile=%dx%d", sp.Interval.Seconds(), sp.Width, height, sp.Columns, sp.Rows)
+	if e := ta.frames(ctx, file, filter, codec, pattern, 0); e != nil {
+		return "", nil, e
+	}
+
+	sub := sp.track(duration, height, filepath.Base(pattern))
+	f, e := os.Create(vtt)
+	if e != nil {
+		return "", nil, e
+	}
+	e = sub.WriteVTT(f)
+	if err := f.Close(); e == nil {
+		e = err
+	}
+	if e != nil {
+		return "", nil, e
+	}
+	return vtt, sequenceFiles(pattern), nil
+}
+
+// tileHeight returns Height or the even height which keeps the aspect of the video
+func (sp *SpriteArgs) tileHeight() (int64, error) {
+	if sp.Height > 0 {
+		return sp.Height, nil
+	}
+	video := sp.StreamFormat.Video()
+	if video == nil || video.Width == nil || video.Height == nil || *video.Width == 0 {
+		return 0, xerrors.New("sprite height need the video size")
+	}
+	return (sp.Width**video.Height / *video.Width + 1) / 2 * 2, nil
+}
+
+// track returns the cues of the tiles, pattern is the image name pattern relative to the track
+func (sp *SpriteArgs) track(duration time.Duration, height int64, pattern string) *Subtitle {
+	sub := &Subtitle{}
+	tiles := sp.Columns * sp.Rows
+	for i := 0; time.Duration(i)*sp.Interval < duration; i++ {
+		start := time.Duration(i) * sp.Interval
+		end := start + sp.Interval
+		if end > duration {
+			end = duration
+		}
+		k := i % tiles
+		x := int64(k%sp.Columns) * sp.Width
+		y := int64(k/sp.Columns) * height
+		sub.Cues = append(sub.Cues, Cue{
+			Start: start,
+			End:   end,
+			Text:  fmt.Sprintf("%s#xywh=%d,%d,%d,%d", fmt.Sprintf(pattern, i/tiles+1), x, y, sp.Width, height),
+		})
+	}
+	return sub
+}
+
+// SpritesOption write the sprites and the track into the split output, the file names are added to SplitArgs.Sprites
+func SpritesOption(opts ...SpriteOptions) SplitOptions {
+	return func(args *SplitArgs) {
+		args.sprites = append(args.sprites, *newSpriteArgs(opts...))
+	}
+}
+
+// writeSprites write the sprites of the split into dir
+func (sa *SplitArgs) writeSprites(ctx Context, file string, dir string) error {
+	for i := range sa.sprites {
+		sp := sa.sprites[i]
+		sp.Output = dir
+		sp.Safe, sp.Root = sa.Safe, sa.Root
+		sp.Limits = sa.Limits
+		if sp.StreamFormat == nil {
+			sp.StreamFormat = sa.StreamFormat
+		}
+		vtt, images, e := sp.run(ctx, file)
+		if e != nil {
+			return e
+		}
+		sa.Sprites = append(sa.Sprites, filepath.Base(vtt))
+		for _, f := range images {
+			sa.Sprites = append(sa.Sprites, filepath.Base(f))
+		}
+	}
+	return nil
+}
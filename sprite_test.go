@@ -0,0 +1,50 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFFMpegSprites ...
+func TestFFMpegSprites(t *testing.T) {
+	defer fakeCommand(t, "ffmpeg", fakeThumbnailFFmpeg)()
+	dir, logFile, cleanup := testLogDir(t, "sprite")
+	defer cleanup()
+	sf := testVideoFormat(t)
+	sf.Format.Duration = "25.000000"
+
+	vtt, images, e := FFMpegSprites(nil, "video.mkv", SpriteStreamFormatOption(sf), SpriteOutputOption(dir, "preview"),
+		SpriteIntervalOption(2*time.Second), SpriteGridOption(3, 2), SpriteSizeOption(160, 0))
+	if e != nil || len(images) != 3 || vtt != filepath.Join(dir, "preview.vtt") {
+		t.Fatal(vtt, images, e)
+	}
+	args, e := ioutil.ReadFile(logFile)
+	if e != nil || !strings.Contains(string(args), "-vf fps=1/2,scale=160:90,tile=3x2 -vsync vfr") {
+		t.Fatal(string(args), e)
+	}
+	f, e := os.Open(vtt)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer f.Close()
+	sub, e := ParseVTT(f)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(sub.Cues) != 13 {
+		t.Fatalf("%+v", sub.Cues)
+	}
+	if c := sub.Cues[4]; c.Start != 8*time.Second || c.Text != "preview-00001.jpg#xywh=160,90,160,90" {
+		t.Fatalf("%+v", c)
+	}
+	if c := sub.Cues[12]; c.End != 25*time.Second || c.Text != "preview-00003.jpg#xywh=0,0,160,90" {
+		t.Fatalf("%+v", c)
+	}
+	if _, _, e := FFMpegSprites(nil, "video.mkv", SpriteStreamFormatOption(sf), SpriteGridOption(0, 2)); e == nil {
+		t.Fatal("want grid error")
+	}
+}
@@ -0,0 +1,161 @@
+package fftool
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+	"golang.org/x/xerrors"
+)
+
+// ErrStitchFormat ...
+var ErrStitchFormat = xerrors.New("the inserted package must use the segment format of the content")
+
+// Insertion a package stitched into the content
+type Insertion struct {
+	At       time.Duration //0 is a pre-roll, after the end is a post-roll
+	Playlist string        //media playlist of the package
+}
+
+// StitchM3U8 write the media playlist content with the insertions to output, an insertion is placed
+// at the first segment boundary at or after At and is surrounded by EXT-X-DISCONTINUITY,
+// the URIs are rewritten relative to output so the packages are not copied
+func StitchM3U8(output string, content string, insertions ...Insertion) error {
+	outDir, e := filepath.Abs(filepath.Dir(output))
+	if e != nil {
+		return e
+	}
+	main, e := readStitchPlaylist(outDir, content)
+	if e != nil {
+		return e
+	}
+	sort.SliceStable(insertions, func(i, j int) bool { return insertions[i].At < insertions[j].At })
+	ads := make([]*m3u8.MediaPlaylist, len(insertions))
+	for i, ins := range insertions {
+		if ads[i], e = readStitchPlaylist(outDir, ins.Playlist); e != nil {
+			return e
+		}
+		if len(ads[i].Segments) > 0 && len(main.Segments) > 0 && (ads[i].Segments[0].Map == nil) != (main.Segments[0].Map == nil) {
+			return ErrStitchFormat
+		}
+		if ads[i].Version > main.Version {
+			main.Version = ads[i].Version
+		}
+	}
+
+	var segments []*m3u8.Segment
+	var key, active *m3u8.Key //key of the content and key of the last segment
+	var init *m3u8.Map
+	add := func(s *m3u8.Segment) {
+		if s.Key != nil {
+			active = s.Key
+		}
+		segments = append(segments, s)
+	}
+	var t time.Duration
+	next := 0
+	insert := func() {
+		for j, s := range ads[next].Segments {
+			c := *s
+			if j == 0 {
+				c.Discontinuity = len(segments) > 0
+				if c.Key == nil && active != nil && active.Method != "NONE" {
+					c.Key = &m3u8.Key{Method: "NONE"}
+				}
+			}
+			add(&c)
+		}
+		next++
+	}
+	for _, s := range main.Segments {
+		inserted := false
+		for next < len(ads) && insertions[next].At <= t+cueTolerance {
+			insert()
+			inserted = true
+		}
+		if inserted && len(segments) > 0 {
+			//resume the content with its key and map
+			s.Discontinuity = true
+			if s.Key == nil && active != key {
+				s.Key = key
+				if s.Key == nil {
+					s.Key = &m3u8.Key{Method: "NONE"}
+				}
+			}
+			if s.Map == nil {
+				s.Map = init
+			}
+		}
+		if s.Key != nil {
+			key = s.Key
+		}
+		if s.Map != nil {
+			init = s.Map
+		}
+		add(s)
+		t += s.Duration
+	}
+	for next < len(ads) {
+		insert()
+	}
+	main.Segments = segments
+	if d := main.MaxDuration(); d > main.TargetDuration {
+		main.TargetDuration = d
+	}
+	if e := os.MkdirAll(outDir, os.ModePerm); e != nil {
+		return e
+	}
+	return m3u8.WriteFile(output, main)
+}
+
+// readStitchPlaylist read the media playlist at path with the URIs relative to dir
+func readStitchPlaylist(dir string, path string) (*m3u8.MediaPlaylist, error) {
+	p, e := readMediaPlaylist(path)
+	if e != nil {
+		return nil, e
+	}
+	src, e := filepath.Abs(filepath.Dir(path))
+	if e != nil {
+		return nil, e
+	}
+	for _, s := range p.Segments {
+		if s.URI, e = stitchURI(dir, src, s.URI); e != nil {
+			return nil, e
+		}
+		if s.Key != nil {
+			if s.Key.URI, e = stitchURI(dir, src, s.Key.URI); e != nil {
+				return nil, e
+			}
+		}
+		if s.Map != nil {
+			if s.Map.URI, e = stitchURI(dir, src, s.Map.URI); e != nil {
+				return nil, e
+			}
+		}
+	}
+	return p, nil
+}
+
+// stitchURI returns uri of the playlist in src relative to dir, absolute URIs are kept
+func stitchURI(dir string, src string, uri string) (string, error) {
+	if uri == "" {
+		return uri, nil
+	}
+	u, e := url.Parse(uri)
+	if e != nil {
+		return "", e
+	}
+	if u.IsAbs() || strings.HasPrefix(u.Path, "/") {
+		return uri, nil
+	}
+	rel, e := filepath.Rel(dir, filepath.Join(src, filepath.FromSlash(u.Path)))
+	if e != nil {
+		return "", e
+	}
+	u.Path = filepath.ToSlash(rel)
+	return u.String(), nil
+}
@@ -0,0 +1,52 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestStitchM3U8 ...
+func TestStitchM3U8(t *testing.T) {
+	dir, e := ioutil.TempDir("", "stitch")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if e := ioutil.WriteFile(path, []byte(content), 0644); e != nil {
+			t.Fatal(e)
+		}
+		return path
+	}
+	content := write("content/media.m3u8", "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXT-X-PLAYLIST-TYPE:VOD\n"+
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXTINF:10,\nc-0.ts\n#EXTINF:10,\nc-1.ts\n#EXTINF:10,\nc-2.ts\n#EXT-X-ENDLIST\n")
+	ad := write("ads/ad.m3u8", "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\na-0.ts\n#EXTINF:4,\nhttp://cdn/a-1.ts\n#EXT-X-ENDLIST\n")
+	output := filepath.Join(dir, "out", "stitched.m3u8")
+
+	if e := StitchM3U8(output, content, Insertion{At: 19 * time.Second, Playlist: ad}, Insertion{Playlist: ad},
+		Insertion{At: time.Hour, Playlist: ad}); e != nil {
+		t.Fatal(e)
+	}
+	b, _ := ioutil.ReadFile(output)
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n" +
+		"#EXTINF:6.000000,\n../ads/a-0.ts\n#EXTINF:4.000000,\nhttp://cdn/a-1.ts\n" +
+		"#EXT-X-DISCONTINUITY\n#EXT-X-KEY:METHOD=AES-128,URI=\"../content/key.bin\"\n#EXTINF:10.000000,\n../content/c-0.ts\n" +
+		"#EXTINF:10.000000,\n../content/c-1.ts\n" +
+		"#EXT-X-DISCONTINUITY\n#EXT-X-KEY:METHOD=NONE\n#EXTINF:6.000000,\n../ads/a-0.ts\n#EXTINF:4.000000,\nhttp://cdn/a-1.ts\n" +
+		"#EXT-X-DISCONTINUITY\n#EXT-X-KEY:METHOD=AES-128,URI=\"../content/key.bin\"\n#EXTINF:10.000000,\n../content/c-2.ts\n" +
+		"#EXT-X-DISCONTINUITY\n#EXT-X-KEY:METHOD=NONE\n#EXTINF:6.000000,\n../ads/a-0.ts\n#EXTINF:4.000000,\nhttp://cdn/a-1.ts\n" +
+		"#EXT-X-ENDLIST\n"
+	if string(b) != want {
+		t.Fatalf("%s\n!=\n%s", string(b), want)
+	}
+
+	fmp4 := write("ads/fmp4.m3u8", "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:6,\na-0.m4s\n#EXT-X-ENDLIST\n")
+	if e := StitchM3U8(output, content, Insertion{Playlist: fmp4}); e != ErrStitchFormat {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,387 @@
+package fftool
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+var vttTiming = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2}[.,]\d{3})\s+-->\s+((?:\d+:)?\d{2}:\d{2}[.,]\d{3})(.*)$`)
+
+// Cue ...
+type Cue struct {
+	ID       string
+	Start    time.Duration
+	End      time.Duration
+	Settings string //webvtt cue settings like "line:0 align:start"
+	Text     string
+}
+
+// Subtitle ...
+type Subtitle struct {
+	Cues []Cue
+}
+
+// ParseVTT ...
+func ParseVTT(r io.Reader) (*Subtitle, error) {
+	scanner := bufio.NewScanner(r)
+	sub := &Subtitle{}
+	var block []string
+	header := true
+	flush := func() error {
+		defer func() { block = block[:0] }()
+		if len(block) == 0 {
+			return nil
+		}
+		if header {
+			header = false
+			if !strings.HasPrefix(strings.TrimPrefix(block[0], "\ufeff"), "WEBVTT") {
+				return xerrors.New("missing WEBVTT header")
+			}
+			return nil
+		}
+		if strings.HasPrefix(block[0], "NOTE") || block[0] == "STYLE" || block[0] == "REGION" {
+			return nil
+		}
+		cue := Cue{}
+		lines := block
+		if !strings.Contains(lines[0], "-->") {
+			cue.ID = lines[0]
+			lines = lines[1:]
+		}
+		if len(lines) == 0 {
+			return xerrors.Errorf("cue %s without timing", cue.ID)
+		}
+		m := vttTiming.FindStringSubmatch(lines[0])
+		if m == nil {
+			return xerrors.Errorf("malformed cue timing:%q", lines[0])
+		}
+		var e error
+		if cue.Start, e = parseTimestamp(m[1]); e != nil {
+			return e
+		}
+		if cue.End, e = parseTimestamp(m[2]); e != nil {
+			return e
+		}
+		cue.Settings = strings.TrimSpace(m[3])
+		cue.Text = strings.Join(lines[1:], "\n")
+		sub.Cues = append(sub.Cues, cue)
+		return nil
+	}
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			if e := flush(); e != nil {
+				return nil, e
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+	if e := flush(); e != nil {
+		return nil, e
+	}
+	if header {
+		return nil, xerrors.New("missing WEBVTT header")
+	}
+	return sub, nil
+}
+
+// WriteVTT ...
+func (s *Subtitle) WriteVTT(w io.Writer) error {
+	return writeVTT(w, "", s.Cues)
+}
+
+func writeVTT(w io.Writer, header string, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n")
+	if header != "" {
+		bw.WriteString(header + "\n")
+	}
+	for _, c := range cues {
+		bw.WriteString("\n")
+		if c.ID != "" {
+			bw.WriteString(c.ID + "\n")
+		}
+		bw.WriteString(formatTimestamp(c.Start, '.') + " --> " + formatTimestamp(c.End, '.'))
+		if c.Settings != "" {
+			bw.WriteString(" " + c.Settings)
+		}
+		bw.WriteString("\n" + c.Text + "\n")
+	}
+	return bw.Flush()
+}
+
+// parseTimestamp parse "01:02:03.456", "02:03.456" or "01:02:03,456"
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.Replace(strings.TrimSpace(s), ",", ".", 1)
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, xerrors.Errorf("malformed timestamp:%q", s)
+	}
+	var d time.Duration
+	for i, p := range parts[:len(parts)-1] {
+		v, e := strconv.ParseInt(p, 10, 64)
+		if e != nil {
+			return 0, xerrors.Errorf("malformed timestamp:%q", s)
+		}
+		if len(parts) == 3 && i == 0 {
+			d += time.Duration(v) * time.Hour
+		} else {
+			d += time.Duration(v) * time.Minute
+		}
+	}
+	sec, e := parseSeconds(parts[len(parts)-1])
+	if e != nil {
+		return 0, xerrors.Errorf("malformed timestamp:%q", s)
+	}
+	return d + sec, nil
+}
+
+// formatTimestamp format as "01:02:03.456", sep is the decimal separator
+func formatTimestamp(d time.Duration, sep byte) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Round(time.Millisecond) / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+var assOverride = regexp.MustCompile(`\{[^}]*\}`)
+
+// ParseSRT ...
+func ParseSRT(r io.Reader) (*Subtitle, error) {
+	scanner := bufio.NewScanner(r)
+	sub := &Subtitle{}
+	var block []string
+	flush := func() error {
+		defer func() { block = block[:0] }()
+		lines := block
+		//the counter line is optional for some files
+		if len(lines) > 0 && !strings.Contains(lines[0], "-->") {
+			lines = lines[1:]
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+		m := vttTiming.FindStringSubmatch(lines[0])
+		if m == nil {
+			return xerrors.Errorf("malformed cue timing:%q", lines[0])
+		}
+		cue := Cue{}
+		var e error
+		if cue.Start, e = parseTimestamp(m[1]); e != nil {
+			return e
+		}
+		if cue.End, e = parseTimestamp(m[2]); e != nil {
+			return e
+		}
+		cue.Text = strings.Join(lines[1:], "\n")
+		sub.Cues = append(sub.Cues, cue)
+		return nil
+	}
+	for scanner.Scan() {
+		line := strings.TrimPrefix(strings.TrimRight(scanner.Text(), "\r"), "\ufeff")
+		if strings.TrimSpace(line) == "" {
+			if e := flush(); e != nil {
+				return nil, e
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+	if e := flush(); e != nil {
+		return nil, e
+	}
+	return sub, nil
+}
+
+// WriteSRT ...
+func (s *Subtitle) WriteSRT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range s.Cues {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n", i+1, formatTimestamp(c.Start, ','), formatTimestamp(c.End, ','), c.Text)
+	}
+	return bw.Flush()
+}
+
+// ParseASS parse the dialogue events, the styling and override tags are dropped
+func ParseASS(r io.Reader) (*Subtitle, error) {
+	scanner := bufio.NewScanner(r)
+	sub := &Subtitle{}
+	events := false
+	var format []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if strings.HasPrefix(line, "[") {
+			events = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !events {
+			continue
+		}
+		if strings.HasPrefix(line, "Format:") {
+			format = strings.Split(strings.TrimPrefix(line, "Format:"), ",")
+			for i := range format {
+				format[i] = strings.TrimSpace(format[i])
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, "Dialogue:") {
+			continue
+		}
+		if len(format) == 0 {
+			return nil, xerrors.New("dialogue before format")
+		}
+		fields := strings.SplitN(strings.TrimPrefix(line, "Dialogue:"), ",", len(format))
+		if len(fields) != len(format) {
+			return nil, xerrors.Errorf("malformed dialogue:%q", line)
+		}
+		cue := Cue{}
+		for i, name := range format {
+			v := strings.TrimSpace(fields[i])
+			var e error
+			switch name {
+			case "Start":
+				cue.Start, e = parseTimestamp(v)
+			case "End":
+				cue.End, e = parseTimestamp(v)
+			case "Text":
+				v = assOverride.ReplaceAllString(fields[i], "")
+				v = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(v)
+				cue.Text = v
+			}
+			if e != nil {
+				return nil, e
+			}
+		}
+		sub.Cues = append(sub.Cues, cue)
+	}
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+	return sub, nil
+}
+
+const assHeader = `[Script Info]
+ScriptType: v4.00+
+PlayResX: 384
+PlayResY: 288
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,16,&Hffffff,&Hffffff,&H0,&H0,0,0,0,0,100,100,0,0,1,1,0,2,10,10,10,0
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+// WriteASS write the cues with the default style
+func (s *Subtitle) WriteASS(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(assHeader)
+	for _, c := range s.Cues {
+		text := strings.Replace(c.Text, "\n", `\N`, -1)
+		fmt.Fprintf(bw, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n", formatASSTimestamp(c.Start), formatASSTimestamp(c.End), text)
+	}
+	return bw.Flush()
+}
+
+// formatASSTimestamp format as "1:02:03.45"
+func formatASSTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	cs := d.Round(10*time.Millisecond) / (10 * time.Millisecond)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
+
+// Shift move all cues by d, cues which end before zero are dropped
+func (s *Subtitle) Shift(d time.Duration) {
+	cues := s.Cues[:0]
+	for _, c := range s.Cues {
+		c.Start += d
+		c.End += d
+		if c.End <= 0 {
+			continue
+		}
+		if c.Start < 0 {
+			c.Start = 0
+		}
+		cues = append(cues, c)
+	}
+	s.Cues = cues
+}
+
+// Scale multiply all cue times by factor, like 25/23.976 for a frame rate conversion
+func (s *Subtitle) Scale(factor float64) {
+	for i := range s.Cues {
+		s.Cues[i].Start = time.Duration(float64(s.Cues[i].Start) * factor)
+		s.Cues[i].End = time.Duration(float64(s.Cues[i].End) * factor)
+	}
+}
+
+// ParseSubtitle parse the format "srt", "vtt", "ass" or "ssa"
+func ParseSubtitle(r io.Reader, format string) (*Subtitle, error) {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "srt", "subrip":
+		return ParseSRT(r)
+	case "vtt", "webvtt":
+		return ParseVTT(r)
+	case "ass", "ssa":
+		return ParseASS(r)
+	}
+	return nil, xerrors.Errorf("unsupported subtitle format:%s", format)
+}
+
+// Write write the format "srt", "vtt", "ass" or "ssa"
+func (s *Subtitle) Write(w io.Writer, format string) error {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "srt", "subrip":
+		return s.WriteSRT(w)
+	case "vtt", "webvtt":
+		return s.WriteVTT(w)
+	case "ass", "ssa":
+		return s.WriteASS(w)
+	}
+	return xerrors.Errorf("unsupported subtitle format:%s", format)
+}
+
+// ConvertSubtitle convert src to dst by the file extensions
+func ConvertSubtitle(src, dst string) error {
+	f, e := os.Open(src)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+	sub, e := ParseSubtitle(f, filepath.Ext(src))
+	if e != nil {
+		return e
+	}
+	out, e := os.Create(dst)
+	if e != nil {
+		return e
+	}
+	e = sub.Write(out, filepath.Ext(dst))
+	if err := out.Close(); e == nil {
+		e = err
+	}
+	return e
+}
@@ -0,0 +1,227 @@
+package fftool
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+)
+
+const groupSubtitles = "subs"
+
+// mpegtsClock ...
+const mpegtsClock = 90000
+
+var textSubtitleCodecs = map[string]bool{
+	"subrip":   true,
+	"srt":      true,
+	"ass":      true,
+	"ssa":      true,
+	"mov_text": true,
+	"webvtt":   true,
+	"text":     true,
+}
+
+var subtitleExts = map[string]bool{
+	".srt": true,
+	".ass": true,
+	".ssa": true,
+	".vtt": true,
+}
+
+// SubtitlesOption package the text subtitle streams and the sidecar subtitle files as WebVTT renditions
+func SubtitlesOption(b bool) SplitOptions {
+	return func(args *SplitArgs) {
+		args.subtitles = b
+	}
+}
+
+// subtitleRenditions add the text subtitle streams and the sidecar files of file as renditions
+func (sa *SplitArgs) subtitleRenditions(file string, sel StreamSelector) {
+	var sources []Rendition
+	if sa.StreamFormat != nil {
+		for _, s := range sel.All(sa.StreamFormat, "subtitle") {
+			s := s
+			if !textSubtitleCodecs[s.CodecName] {
+				log.With("index", s.Index, "codec", s.CodecName).Warn("skip image subtitle")
+				continue
+			}
+			sources = append(sources, Rendition{
+				Name:     renditionName(&s, len(sources)),
+				Language: renditionLanguage(&s),
+				Default:  s.IsDefault(),
+				Forced:   s.IsForced(),
+				Stream:   &s,
+			})
+		}
+	}
+	for _, f := range sidecarSubtitles(file) {
+		lang := sidecarLanguage(file, f)
+		name := lang
+		if name == "" {
+			name = filepath.Base(f)
+		}
+		sources = append(sources, Rendition{Name: name, Language: lang, File: f})
+	}
+	for i, r := range sources {
+		r.Type = "SUBTITLES"
+		r.Group = groupSubtitles
+		r.Codec = "webvtt"
+		r.URI = variantName(fmt.Sprintf("%s%d", groupSubtitles, i), sa.M3U8)
+		sa.Renditions = append(sa.Renditions, r)
+	}
+}
+
+// sidecarSubtitles returns the subtitle files next to file, like video.srt or video.eng.srt
+func sidecarSubtitles(file string) []string {
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	infos, e := ioutil.ReadDir(filepath.Dir(file))
+	if e != nil {
+		return nil
+	}
+	var files []string
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !subtitleExts[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		if name == base+filepath.Ext(name) || strings.HasPrefix(name, base+".") {
+			files = append(files, filepath.Join(filepath.Dir(file), name))
+		}
+	}
+	return files
+}
+
+// sidecarLanguage returns "eng" of video.eng.srt
+func sidecarLanguage(file, sidecar string) string {
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	name := strings.TrimSuffix(filepath.Base(sidecar), filepath.Ext(sidecar))
+	return strings.TrimPrefix(strings.TrimPrefix(name, base), ".")
+}
+
+// writeSubtitles convert the subtitle renditions to WebVTT segments aligned with the video segments
+func (sa *SplitArgs) writeSubtitles(ctx Context, file string, dir string) error {
+	video, e := readMediaPlaylist(filepath.Join(dir, sa.videoPlaylist()))
+	if e != nil {
+		return e
+	}
+	offset := sa.timestampOffset(dir, video)
+	for i, r := range sa.renditions("SUBTITLES") {
+		full := filepath.Join(dir, fmt.Sprintf(".%s%d.vtt", groupSubtitles, i))
+		args := []string{"-y"}
+		if sa.Safe {
+			args = append(args, strings.Fields(safeInputOptions)...)
+		}
+		if r.File != "" {
+			src := r.File
+			if sa.Safe {
+				src = "file:" + src
+			}
+			args = append(args, "-i", src)
+		} else {
+			args = append(args, "-i", file, "-map", fmt.Sprintf("0:%d", r.Stream.Index))
+		}
+		ffmpeg := NewFFMpeg()
+		ffmpeg.Args = append(args, "-c:s", "webvtt", "-f", "webvtt", full)
+		ffmpeg.Limits = sa.Limits
+		if e := ffmpegRun(ctx, ffmpeg); e != nil {
+			return e
+		}
+		b, e := ioutil.ReadFile(full)
+		_ = os.Remove(full)
+		if e != nil {
+			return e
+		}
+		sub, e := ParseVTT(bytes.NewReader(b))
+		if e != nil {
+			return e
+		}
+		prefix := strings.TrimSuffix(r.URI, filepath.Ext(r.URI))
+		if e := writeVTTSegments(dir, prefix, r.URI, sub, video, offset); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// videoPlaylist returns the media playlist of the video
+func (sa *SplitArgs) videoPlaylist() string {
+	if sa.master() {
+		return variantName(variantVideo, sa.M3U8)
+	}
+	return sa.M3U8
+}
+
+// timestampOffset returns the mpegts timestamp of the first video segment for X-TIMESTAMP-MAP
+func (sa *SplitArgs) timestampOffset(dir string, video *m3u8.MediaPlaylist) int64 {
+	if len(video.Segments) == 0 {
+		return 0
+	}
+	probe := FFProbeStreamFormat
+	if sa.Safe {
+		probe = FFProbeStreamFormatSafe
+	}
+	sf, e := probe(filepath.Join(dir, video.Segments[0].URI))
+	if e != nil {
+		log.Error(e)
+		return 0
+	}
+	start, e := sf.Format.StartTimeValue()
+	if e != nil {
+		log.Error(e)
+		return 0
+	}
+	return int64(math.Round(start.Seconds() * mpegtsClock))
+}
+
+// readMediaPlaylist parse the media playlist at path
+func readMediaPlaylist(path string) (*m3u8.MediaPlaylist, error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+	return m3u8.ParseMedia(f)
+}
+
+// writeVTTSegments split sub by the segments of video and write the subtitle playlist
+func writeVTTSegments(dir, prefix, playlist string, sub *Subtitle, video *m3u8.MediaPlaylist, offset int64) error {
+	list := &m3u8.MediaPlaylist{
+		Version:        3,
+		TargetDuration: video.TargetDuration,
+		PlaylistType:   "VOD",
+		EndList:        true,
+	}
+	header := fmt.Sprintf("X-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000", offset)
+	var start time.Duration
+	for i, seg := range video.Segments {
+		end := start + seg.Duration
+		var cues []Cue
+		for _, c := range sub.Cues {
+			if c.End > start && c.Start < end {
+				cues = append(cues, c)
+			}
+		}
+		name := fmt.Sprintf("%s-%05d.vtt", prefix, i)
+		f, e := os.Create(filepath.Join(dir, name))
+		if e != nil {
+			return e
+		}
+		e = writeVTT(f, header, cues)
+		if err := f.Close(); e == nil {
+			e = err
+		}
+		if e != nil {
+			return e
+		}
+		list.Segments = append(list.Segments, &m3u8.Segment{URI: name, Duration: seg.Duration})
+		start = end
+	}
+	return m3u8.WriteFile(filepath.Join(dir, playlist), list)
+}
@@ -0,0 +1,55 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSplitArgs_WriteSubtitles ...
+func TestSplitArgs_WriteSubtitles(t *testing.T) {
+	dir, e := ioutil.TempDir("", "fftool")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "movie.mp4")
+	_ = ioutil.WriteFile(file, nil, 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "movie.eng.vtt"), []byte(testVTT), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "other.srt"), nil, 0644)
+
+	sa := &SplitArgs{M3U8: "media.m3u8"}
+	sa.subtitleRenditions(file, DefaultStreamSelector())
+	subs := sa.renditions("SUBTITLES")
+	if len(subs) != 1 || subs[0].Language != "eng" || subs[0].URI != "subs0_media.m3u8" {
+		t.Fatalf("%+v", subs)
+	}
+
+	out := filepath.Join(dir, "out")
+	_ = os.MkdirAll(out, os.ModePerm)
+	_ = ioutil.WriteFile(filepath.Join(out, sa.videoPlaylist()), []byte("#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10.000000,\nvideo_media-00000.ts\n#EXTINF:10.000000,\nvideo_media-00001.ts\n#EXTINF:5.000000,\nvideo_media-00002.ts\n#EXT-X-ENDLIST\n"), 0644)
+	//ffmpeg -y -i <src> -c:s webvtt -f webvtt <out>
+	defer fakeCommand(t, "ffmpeg", `for last; do :; done; cp "$3" "$last"`+"\n")()
+	defer fakeCommand(t, "ffprobe", "exit 1\n")()
+	ctx := FFmpegContext()
+	if e := sa.writeSubtitles(ctx, file, out); e != nil {
+		t.Fatal(e)
+	}
+	list, _ := ioutil.ReadFile(filepath.Join(out, "subs0_media.m3u8"))
+	if !strings.Contains(string(list), "#EXTINF:5.000000,\nsubs0_media-00002.vtt\n#EXT-X-ENDLIST") {
+		t.Fatal(string(list))
+	}
+	seg0, _ := ioutil.ReadFile(filepath.Join(out, "subs0_media-00000.vtt"))
+	if !strings.Contains(string(seg0), "X-TIMESTAMP-MAP=MPEGTS:0,LOCAL:00:00:00.000") || !strings.Contains(string(seg0), "Hello") {
+		t.Fatal(string(seg0))
+	}
+	seg1, _ := ioutil.ReadFile(filepath.Join(out, "subs0_media-00001.vtt"))
+	if strings.Contains(string(seg1), "Hello") || strings.Contains(string(seg1), "Second") {
+		t.Fatal(string(seg1))
+	}
+	if _, e := os.Stat(filepath.Join(out, ".subs0.vtt")); !os.IsNotExist(e) {
+		t.Fatal("temporary vtt not removed")
+	}
+}
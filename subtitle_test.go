@@ -0,0 +1,157 @@
+package fftool
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testVTT = "WEBVTT\n\nNOTE comment\n\n1\n00:00:01.000 --> 00:00:04.500 line:0\nHello\nWorld\n\n01:02.000 --> 01:05.250\nSecond\n"
+
+// TestParseVTT ...
+func TestParseVTT(t *testing.T) {
+	sub, e := ParseVTT(strings.NewReader(testVTT))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(sub.Cues) != 2 {
+		t.Fatalf("%+v", sub.Cues)
+	}
+	c := sub.Cues[0]
+	if c.ID != "1" || c.Start != time.Second || c.End != 4500*time.Millisecond || c.Settings != "line:0" || c.Text != "Hello\nWorld" {
+		t.Fatalf("%+v", c)
+	}
+	if sub.Cues[1].Start != 62*time.Second {
+		t.Fatal(sub.Cues[1].Start)
+	}
+	var buf bytes.Buffer
+	if e := sub.WriteVTT(&buf); e != nil {
+		t.Fatal(e)
+	}
+	sub2, e := ParseVTT(&buf)
+	if e != nil || len(sub2.Cues) != 2 || sub2.Cues[1] != sub.Cues[1] {
+		t.Fatal(e, sub2)
+	}
+	if _, e := ParseVTT(strings.NewReader("1\n00:00:01.000 --> 00:00:02.000\nx\n")); e == nil {
+		t.Fatal("want header error")
+	}
+}
+
+// TestFormatTimestamp ...
+func TestFormatTimestamp(t *testing.T) {
+	d := time.Hour + 2*time.Minute + 3*time.Second + 456*time.Millisecond
+	if s := formatTimestamp(d, ','); s != "01:02:03,456" {
+		t.Fatal(s)
+	}
+	if v, e := parseTimestamp("01:02:03,456"); e != nil || v != d {
+		t.Fatal(v, e)
+	}
+}
+
+const testSRT = "\ufeff1\r\n00:00:01,000 --> 00:00:02,500\r\nHello\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nTwo\r\nLines\r\n"
+
+const testASS = `[Script Info]
+Title: test
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:01.00,0:00:02.50,Default,,0,0,0,,{\b1}Hello{\b0}, world
+Dialogue: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,Two\NLines
+`
+
+// TestParseSRT ...
+func TestParseSRT(t *testing.T) {
+	sub, e := ParseSRT(strings.NewReader(testSRT))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(sub.Cues) != 2 || sub.Cues[0].End != 2500*time.Millisecond || sub.Cues[1].Text != "Two\nLines" {
+		t.Fatalf("%+v", sub.Cues)
+	}
+	var buf bytes.Buffer
+	if e := sub.WriteSRT(&buf); e != nil {
+		t.Fatal(e)
+	}
+	if !strings.HasPrefix(buf.String(), "1\n00:00:01,000 --> 00:00:02,500\nHello\n\n2\n") {
+		t.Fatal(buf.String())
+	}
+	if _, e := ParseSRT(strings.NewReader("1\nnot a timing\nx\n")); e == nil {
+		t.Fatal("want timing error")
+	}
+}
+
+// TestParseASS ...
+func TestParseASS(t *testing.T) {
+	sub, e := ParseASS(strings.NewReader(testASS))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(sub.Cues) != 2 || sub.Cues[0].Text != "Hello, world" || sub.Cues[1].Text != "Two\nLines" {
+		t.Fatalf("%+v", sub.Cues)
+	}
+	if sub.Cues[0].Start != time.Second || sub.Cues[0].End != 2500*time.Millisecond {
+		t.Fatalf("%+v", sub.Cues[0])
+	}
+	var buf bytes.Buffer
+	if e := sub.WriteASS(&buf); e != nil {
+		t.Fatal(e)
+	}
+	if !strings.Contains(buf.String(), "Dialogue: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,Two\\NLines\n") {
+		t.Fatal(buf.String())
+	}
+	sub2, e := ParseASS(&buf)
+	if e != nil || len(sub2.Cues) != 2 || sub2.Cues[1] != sub.Cues[1] {
+		t.Fatal(e, sub2)
+	}
+}
+
+// TestSubtitle_ShiftScale ...
+func TestSubtitle_ShiftScale(t *testing.T) {
+	sub, e := ParseSRT(strings.NewReader(testSRT))
+	if e != nil {
+		t.Fatal(e)
+	}
+	sub.Shift(-2 * time.Second)
+	if len(sub.Cues) != 2 || sub.Cues[0].Start != 0 || sub.Cues[0].End != 500*time.Millisecond {
+		t.Fatalf("%+v", sub.Cues)
+	}
+	sub.Shift(-time.Second)
+	if len(sub.Cues) != 1 || sub.Cues[0].Start != 0 || sub.Cues[0].End != time.Second {
+		t.Fatalf("%+v", sub.Cues)
+	}
+	sub.Scale(2)
+	if sub.Cues[0].End != 2*time.Second {
+		t.Fatalf("%+v", sub.Cues)
+	}
+}
+
+// TestConvertSubtitle ...
+func TestConvertSubtitle(t *testing.T) {
+	dir, e := ioutil.TempDir("", "subtitle")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "a.srt")
+	if e := ioutil.WriteFile(src, []byte(testSRT), 0644); e != nil {
+		t.Fatal(e)
+	}
+	dst := filepath.Join(dir, "a.vtt")
+	if e := ConvertSubtitle(src, dst); e != nil {
+		t.Fatal(e)
+	}
+	b, e := ioutil.ReadFile(dst)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !strings.HasPrefix(string(b), "WEBVTT\n") || !strings.Contains(string(b), "00:00:03.000 --> 00:00:04.000\nTwo\nLines") {
+		t.Fatal(string(b))
+	}
+	if e := ConvertSubtitle(src, filepath.Join(dir, "a.sub")); e == nil {
+		t.Fatal("want unsupported format error")
+	}
+}
@@ -0,0 +1,445 @@
+package fftool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ThumbnailMode ...
+type ThumbnailMode int
+
+// ThumbnailAt ...
+const (
+	// ThumbnailAt a single frame at Time
+	ThumbnailAt ThumbnailMode = iota
+	// ThumbnailCount Count evenly spaced frames
+	ThumbnailCount
+	// ThumbnailInterval one frame every Interval
+	ThumbnailInterval
+	// ThumbnailBest Count representative frames chosen by the thumbnail filter
+	ThumbnailBest
+)
+
+// defaultThumbnailBatch the frames analysed by the thumbnail filter when the frame rate is unknown
+const defaultThumbnailBatch = 100
+
+// maxThumbnailBatch the most frames analysed for a part, the thumbnail filter keeps the whole batch decoded in memory
+const maxThumbnailBatch = 300
+
+// defaultThumbnailName the file name prefix of the thumbnails
+const defaultThumbnailName = "thumb"
+
+// ThumbnailArgs ...
+type ThumbnailArgs struct {
+	Mode         ThumbnailMode
+	Time         time.Duration
+	Count        int
+	Interval     time.Duration
+	Width        int64 //0 keeps the aspect of Height
+	Height       int64 //0 keeps the aspect of Width
+	Format       string
+	Output       string
+	Name         string
+	StreamFormat *StreamFormat
+	Safe         bool
+	Root         string
+	Limits       *Limits
+}
+
+// ThumbnailOptions ...
+type ThumbnailOptions func(args *ThumbnailArgs)
+
+// ThumbnailAtOption extract one frame at t
+func ThumbnailAtOption(t time.Duration) ThumbnailOptions {
+	return func(args *ThumbnailArgs) {
+		args.Mode = ThumbnailAt
+		args.Time = t
+	}
+}
+
+// ThumbnailCountOption extract n frames evenly spaced over the duration
+func ThumbnailCountOption(n int) ThumbnailOptions {
+	return func(args *ThumbnailArgs) {
+		args.Mode = ThumbnailCount
+		args.Count = n
+	}
+}
+
+// ThumbnailIntervalOption extract one frame every d
+func ThumbnailIntervalOption(d time.Duration) ThumbnailOptions {
+	return func(args *ThumbnailArgs) {
+		args.Mode = ThumbnailInterval
+		args.Interval = d
+	}
+}
+
+// ThumbnailBestOption extract n representative frames, one from every part of the video
+func ThumbnailBestOption(n int) ThumbnailOptions {
+	return func(args *ThumbnailArgs) {
+		args.Mode = ThumbnailBest
+		args.Count = n
+	}
+}
+
+// ThumbnailSizeOption scale the frames, a zero side keeps the aspect
+func ThumbnailSizeOption(w, h int64) ThumbnailOptions {
+	return func(args *ThumbnailArgs) {
+		args.Width = w
+		args.Height = h
+	}
+}
+
+// ThumbnailFormatOption set the image format: jpg, png or webp
+func ThumbnailFormatOption(f string) ThumbnailOptions {
+	return func(args *ThumbnailArgs) {
+		args.Format = f
+	}
+}
+
+// ThumbnailOutputOption set the output directory and the file name prefix
+func ThumbnailOutputOption(dir string, name string) ThumbnailOptions {
+	return func(args *ThumbnailArgs) {
+		args.Output = dir
+		args.Name = name
+	}
+}
+
+// ThumbnailStreamFormatOption use the probed info instead of running ffprobe
+func ThumbnailStreamFormatOption(sf *StreamFormat) ThumbnailOptions {
+	return func(args *ThumbnailArgs) {
+		args.StreamFormat = sf
+	}
+}
+
+// ThumbnailSafeOption only read a local file and write below root
+func ThumbnailSafeOption(root string) ThumbnailOptions {
+	return func(args *ThumbnailArgs) {
+		args.Safe = true
+		args.Root = root
+	}
+}
+
+func newThumbnailArgs(opts ...ThumbnailOptions) *ThumbnailArgs {
+	ta := &ThumbnailArgs{
+		Mode:   ThumbnailAt,
+		Format: "jpg",
+		Output: ".",
+	}
+	for _, o := range opts {
+		o(ta)
+	}
+	return ta
+}
+
+// FFMpegThumbnails extract the thumbnails of file, returns the written files
+func FFMpegThumbnails(ctx Context, file string, opts ...ThumbnailOptions) ([]string, error) {
+	if ctx == nil {
+		ctx = FFmpegContext()
+	}
+	ta := newThumbnailArgs(opts...)
+	if ta.Name == "" {
+		ta.Name = defaultThumbnailName
+	}
+	if ta.Safe {
+		if e := checkSafeInput(file); e != nil {
+			return nil, e
+		}
+	}
+	return ta.run(ctx, file)
+}
+
+// run extract the thumbnails, the input is already checked
+func (ta *ThumbnailArgs) run(ctx Context, file string) ([]string, error) {
+	ext, codec, e := thumbnailCodec(ta.Format)
+	if e != nil {
+		return nil, e
+	}
+	output, e := filepath.Abs(ta.Output)
+	if e != nil {
+		return nil, e
+	}
+	if (ta.Mode == ThumbnailCount || ta.Mode == ThumbnailBest) && ta.Count <= 0 {
+		return nil, xerrors.Errorf("wrong thumbnail count:%d", ta.Count)
+	}
+	if ta.Mode == ThumbnailInterval && ta.Interval <= 0 {
+		return nil, xerrors.Errorf("wrong thumbnail interval:%s", ta.Interval)
+	}
+	if ta.StreamFormat == nil && (ta.Mode == ThumbnailCount || ta.Mode == ThumbnailBest) {
+		probe := FFProbeStreamFormat
+		if ta.Safe {
+			probe = FFProbeStreamFormatSafe
+		}
+		if ta.StreamFormat, e = probe(file); e != nil {
+			return nil, e
+		}
+	}
+	single := filepath.Join(output, ta.Name+ext)
+	pattern := filepath.Join(output, ta.Name+"-%05d"+ext)
+	if ta.Safe {
+		if e := checkSafeOutput(ta.Root, output, single, pattern); e != nil {
+			return nil, e
+		}
+		file = "file:" + file
+	}
+	if e := os.MkdirAll(output, os.ModePerm); e != nil {
+		return nil, e
+	}
+
+	switch ta.Mode {
+	case ThumbnailAt:
+		if e := ta.frame(ctx, file, ta.Time, codec, single); e != nil {
+			return nil, e
+		}
+		return []string{single}, nil
+	case ThumbnailCount:
+		duration, e := ta.StreamFormat.Format.DurationValue()
+		if e != nil {
+			return nil, e
+		}
+		var files []string
+		for _, t := range thumbnailTimes(duration, ta.Count) {
+			name := fmt.Sprintf(pattern, len(files)+1)
+			if e := ta.frame(ctx, file, t, codec, name); e != nil {
+				return nil, e
+			}
+			files = append(files, name)
+		}
+		return files, nil
+	case ThumbnailInterval:
+		filter := fmt.Sprintf("fps=1/%g", ta.Interval.Seconds())
+		if e := ta.frames(ctx, file, filter, codec, pattern, 0); e != nil {
+			return nil, e
+		}
+	case ThumbnailBest:
+		duration, e := ta.StreamFormat.Format.DurationValue()
+		if e != nil {
+			return nil, e
+		}
+		var files []string
+		for _, w := range ta.bestWindows(duration) {
+			name := fmt.Sprintf(pattern, len(files)+1)
+			if e := ta.best(ctx, file, w, codec, name); e != nil {
+				return nil, e
+			}
+			files = append(files, name)
+		}
+		return files, nil
+	default:
+		return nil, xerrors.Errorf("unknown thumbnail mode:%d", ta.Mode)
+	}
+	return sequenceFiles(pattern), nil
+}
+
+// frame write the single frame at t
+func (ta *ThumbnailArgs) frame(ctx Context, file string, t time.Duration, codec []string, name string) error {
+	args := ta.inputArgs()
+	//seek before the input to decode from the nearest keyframe only
+	args = append(args, "-ss", fmt.Sprintf("%.3f", t.Seconds()), "-i", file, "-map", "0:V:0", "-frames:v", "1")
+	if scale := ta.scaleFilter(); scale != "" {
+		args = append(args, "-vf", scale)
+	}
+	return ta.ffmpeg(ctx, append(append(args, codec...), name))
+}
+
+// best write the representative frame of the window chosen by the thumbnail filter
+func (ta *ThumbnailArgs) best(ctx Context, file string, w thumbnailWindow, codec []string, name string) error {
+	filter := fmt.Sprintf("thumbnail=%d", w.batch)
+	if scale := ta.scaleFilter(); scale != "" {
+		filter += "," + scale
+	}
+	args := append(ta.inputArgs(), "-ss", fmt.Sprintf("%.3f", w.start.Seconds()), "-t", fmt.Sprintf("%.3f", w.length.Seconds()),
+		"-i", file, "-map", "0:V:0", "-vf", filter, "-frames:v", "1")
+	return ta.ffmpeg(ctx, append(append(args, codec...), name))
+}
+
+// frames write the frames selected by filter to the numbered pattern, max 0 is unlimited.
+// The files of an earlier run are removed so only the written frames are in the sequence
+func (ta *ThumbnailArgs) frames(ctx Context, file string, filter string, codec []string, pattern string, max int) error {
+	if scale := ta.scaleFilter(); scale != "" {
+		filter += "," + scale
+	}
+	if e := removeSequence(pattern); e != nil {
+		return e
+	}
+	args := append(ta.inputArgs(), "-i", file, "-map", "0:V:0", "-vf", filter, "-vsync", "vfr")
+	if max > 0 {
+		args = append(args, "-frames:v", fmt.Sprint(max))
+	}
+	return ta.ffmpeg(ctx, append(append(args, codec...), pattern))
+}
+
+func (ta *ThumbnailArgs) inputArgs() []string {
+	args := []string{"-y"}
+	if ta.Safe {
+		args = append(args, strings.Fields(safeInputOptions)...)
+	}
+	input, _ := ta.Limits.args()
+	return append(args, strings.Fields(input)...)
+}
+
+func (ta *ThumbnailArgs) ffmpeg(ctx Context, args []string) error {
+	ffmpeg := NewFFMpeg()
+	ffmpeg.Args = args
+	ffmpeg.Limits = ta.Limits
+	return ffmpegRun(ctx, ffmpeg)
+}
+
+// scaleFilter returns the scale filter of the thumbnail size
+func (ta *ThumbnailArgs) scaleFilter() string {
+	if ta.Width <= 0 && ta.Height <= 0 {
+		return ""
+	}
+	w, h := ta.Width, ta.Height
+	if w <= 0 {
+		w = -2
+	}
+	if h <= 0 {
+		h = -2
+	}
+	return fmt.Sprintf("scale=%d:%d", w, h)
+}
+
+// thumbnailWindow the part of the video analysed for a representative frame
+type thumbnailWindow struct {
+	start  time.Duration
+	length time.Duration
+	batch  int64
+}
+
+// bestWindows returns the window of every part when the video is split into Count parts, the window
+// is the middle of the part and holds at most maxThumbnailBatch frames
+func (ta *ThumbnailArgs) bestWindows(duration time.Duration) []thumbnailWindow {
+	part := duration / time.Duration(ta.Count)
+	batch, length := int64(defaultThumbnailBatch), part
+	if rate := ta.frameRate(); rate > 0 {
+		batch = int64(part.Seconds() * rate)
+		if batch > maxThumbnailBatch {
+			batch = maxThumbnailBatch
+		}
+		if batch < 1 {
+			batch = 1
+		}
+		length = time.Duration(float64(batch) / rate * float64(time.Second))
+	}
+	windows := make([]thumbnailWindow, ta.Count)
+	for i := range windows {
+		windows[i] = thumbnailWindow{start: part*time.Duration(i) + (part-length)/2, length: length, batch: batch}
+	}
+	return windows
+}
+
+// frameRate returns the frame rate of the video, 0 when it is unknown
+func (ta *ThumbnailArgs) frameRate() float64 {
+	video := ta.StreamFormat.Video()
+	if video == nil {
+		return 0
+	}
+	rate, e := video.FrameRate()
+	if e != nil {
+		return 0
+	}
+	return rate.Float64()
+}
+
+// thumbnailTimes returns the middle of every part when duration is split into n parts
+func thumbnailTimes(duration time.Duration, n int) []time.Duration {
+	times := make([]time.Duration, n)
+	for i := range times {
+		times[i] = duration * time.Duration(2*i+1) / time.Duration(2*n)
+	}
+	return times
+}
+
+// thumbnailCodec returns the file extension and the encoder arguments of format
+func thumbnailCodec(format string) (string, []string, error) {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "jpg", "jpeg", "":
+		return ".jpg", []string{"-q:v", "2"}, nil
+	case "png":
+		return ".png", nil, nil
+	case "webp":
+		return ".webp", []string{"-c:v", "libwebp"}, nil
+	}
+	return "", nil, xerrors.Errorf("unsupported thumbnail format:%s", format)
+}
+
+// removeSequence remove the files of the numbered pattern, the pattern number is %05d
+func removeSequence(pattern string) error {
+	dir, base := filepath.Split(pattern)
+	i := strings.Index(base, "%05d")
+	infos, e := ioutil.ReadDir(dir)
+	if i < 0 || os.IsNotExist(e) {
+		return nil
+	}
+	if e != nil {
+		return e
+	}
+	prefix, suffix := base[:i], base[i+len("%05d"):]
+	for _, info := range infos {
+		name := info.Name()
+		if len(name) != len(prefix)+5+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		if strings.Trim(name[len(prefix):len(prefix)+5], "0123456789") != "" {
+			continue
+		}
+		if e := os.Remove(filepath.Join(dir, name)); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// sequenceFiles returns the existing files of the numbered pattern which starts at 1
+func sequenceFiles(pattern string) []string {
+	var files []string
+	for i := 1; ; i++ {
+		name := fmt.Sprintf(pattern, i)
+		if _, e := os.Stat(name); e != nil {
+			return files
+		}
+		files = append(files, name)
+	}
+}
+
+// ThumbnailsOption write the thumbnails into the split output, the file names are added to SplitArgs.Thumbnails
+func ThumbnailsOption(opts ...ThumbnailOptions) SplitOptions {
+	return func(args *SplitArgs) {
+		ta := newThumbnailArgs(opts...)
+		if ta.Name == "" {
+			//every unnamed set has its own prefix
+			ta.Name = defaultThumbnailName
+			if n := len(args.thumbnails); n > 0 {
+				ta.Name += fmt.Sprint(n + 1)
+			}
+		}
+		args.thumbnails = append(args.thumbnails, *ta)
+	}
+}
+
+// writeThumbnails extract the thumbnails of the split into dir
+func (sa *SplitArgs) writeThumbnails(ctx Context, file string, dir string) error {
+	for i := range sa.thumbnails {
+		ta := sa.thumbnails[i]
+		ta.Output = dir
+		ta.Safe, ta.Root = sa.Safe, sa.Root
+		ta.Limits = sa.Limits
+		if ta.StreamFormat == nil {
+			ta.StreamFormat = sa.StreamFormat
+		}
+		files, e := ta.run(ctx, file)
+		if e != nil {
+			return e
+		}
+		for _, f := range files {
+			sa.Thumbnails = append(sa.Thumbnails, filepath.Base(f))
+		}
+	}
+	return nil
+}
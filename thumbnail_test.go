@@ -0,0 +1,86 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeThumbnailFFmpeg record the arguments to log and create the output, a pattern output creates 3 files
+const fakeThumbnailFFmpeg = fakeLog + `for out; do :; done
+case "$out" in
+*%05d*) for i in 1 2 3; do touch "$(printf "$out" $i)"; done ;;
+*) touch "$out" ;;
+esac
+`
+
+// TestFFMpegThumbnails ...
+func TestFFMpegThumbnails(t *testing.T) {
+	defer fakeCommand(t, "ffmpeg", fakeThumbnailFFmpeg)()
+	dir, logFile, cleanup := testLogDir(t, "thumbnail")
+	defer cleanup()
+	out := filepath.Join(dir, "out")
+	sf := testVideoFormat(t)
+	sf.Format.Duration = "100.000000"
+	sf.Streams[0].RFrameRate = "25/1"
+
+	lastArgs := func() string {
+		b, e := ioutil.ReadFile(logFile)
+		if e != nil {
+			t.Fatal(e)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		return lines[len(lines)-1]
+	}
+
+	files, e := FFMpegThumbnails(nil, "video.mkv", ThumbnailAtOption(90*time.Second), ThumbnailOutputOption(out, "poster"), ThumbnailSizeOption(320, 0))
+	if e != nil || len(files) != 1 || files[0] != filepath.Join(out, "poster.jpg") {
+		t.Fatal(files, e)
+	}
+	if a := lastArgs(); !strings.Contains(a, "-ss 90.000 -i video.mkv") || !strings.Contains(a, "-vf scale=320:-2 -q:v 2") {
+		t.Fatal(a)
+	}
+
+	files, e = FFMpegThumbnails(nil, "video.mkv", ThumbnailCountOption(4), ThumbnailStreamFormatOption(sf), ThumbnailOutputOption(out, "count"), ThumbnailFormatOption("png"))
+	if e != nil || len(files) != 4 || files[3] != filepath.Join(out, "count-00004.png") {
+		t.Fatal(files, e)
+	}
+	if a := lastArgs(); !strings.Contains(a, "-ss 87.500 ") {
+		t.Fatal(a)
+	}
+
+	//the frames of an earlier run are not returned
+	_ = ioutil.WriteFile(filepath.Join(out, "interval-00004.jpg"), nil, 0644)
+	files, e = FFMpegThumbnails(nil, "video.mkv", ThumbnailIntervalOption(2500*time.Millisecond), ThumbnailOutputOption(out, "interval"))
+	if e != nil || len(files) != 3 {
+		t.Fatal(files, e)
+	}
+	if a := lastArgs(); !strings.Contains(a, "-vf fps=1/2.5 -vsync vfr") {
+		t.Fatal(a)
+	}
+
+	files, e = FFMpegThumbnails(nil, "video.mkv", ThumbnailBestOption(5), ThumbnailStreamFormatOption(sf), ThumbnailOutputOption(out, "best"), ThumbnailFormatOption("webp"))
+	if e != nil || len(files) != 5 || files[4] != filepath.Join(out, "best-00005.webp") {
+		t.Fatal(files, e)
+	}
+	//the 500 frames of a part are capped to the middle 300
+	if a := lastArgs(); !strings.Contains(a, "-ss 84.000 -t 12.000 -i video.mkv -map 0:V:0 -vf thumbnail=300 -frames:v 1 -c:v libwebp") {
+		t.Fatal(a)
+	}
+
+	if _, e = FFMpegThumbnails(nil, "video.mkv", ThumbnailFormatOption("gif")); e == nil {
+		t.Fatal("want format error")
+	}
+	if _, e = FFMpegThumbnails(nil, "video.mkv", ThumbnailCountOption(0)); e == nil {
+		t.Fatal("want count error")
+	}
+
+	sa := &SplitArgs{}
+	ThumbnailsOption(ThumbnailIntervalOption(time.Second))(sa)
+	ThumbnailsOption(ThumbnailBestOption(3))(sa)
+	if sa.thumbnails[0].Name != "thumb" || sa.thumbnails[1].Name != "thumb2" {
+		t.Fatal(sa.thumbnails[0].Name, sa.thumbnails[1].Name)
+	}
+}
@@ -0,0 +1,277 @@
+package fftool
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/glvd/go-fftool/m3u8"
+	"golang.org/x/xerrors"
+)
+
+// IssueKind ...
+type IssueKind string
+
+// IssuePlaylist ...
+const (
+	IssuePlaylist       IssueKind = "playlist"        //the playlist is missing or malformed
+	IssueMissingSegment IssueKind = "missing_segment" //a referenced segment does not exist
+	IssueEmptySegment   IssueKind = "empty_segment"
+	IssueTargetDuration IssueKind = "target_duration" //a segment is longer than TARGETDURATION
+	IssueDuration       IssueKind = "duration"        //the total duration does not match the source
+	IssueEndList        IssueKind = "endlist"
+	IssueDecode         IssueKind = "decode" //ffprobe cannot read the segment
+)
+
+// Issue a problem found by the validator
+type Issue struct {
+	Kind     IssueKind
+	Playlist string
+	Segment  string
+	Message  string
+}
+
+// String ...
+func (i Issue) String() string {
+	s := fmt.Sprintf("%s %s", i.Kind, i.Playlist)
+	if i.Segment != "" {
+		s += " " + i.Segment
+	}
+	return s + ": " + i.Message
+}
+
+// ValidationReport ...
+type ValidationReport struct {
+	Playlists []string
+	Segments  int
+	Probed    int
+	Duration  time.Duration //duration of the first media playlist
+	Issues    []Issue
+}
+
+// OK reports whether no issue is found
+func (r *ValidationReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Err returns a *ValidationError when an issue is found
+func (r *ValidationReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return &ValidationError{Report: r}
+}
+
+func (r *ValidationReport) add(kind IssueKind, playlist, segment, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{Kind: kind, Playlist: playlist, Segment: segment, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidationError ...
+type ValidationError struct {
+	Report *ValidationReport
+}
+
+// Error ...
+func (e *ValidationError) Error() string {
+	var issues []string
+	for _, i := range e.Report.Issues {
+		issues = append(issues, i.String())
+	}
+	return fmt.Sprintf("hls validation failed with %d issue(s): %s", len(issues), strings.Join(issues, "; "))
+}
+
+// ValidateArgs ...
+type ValidateArgs struct {
+	Duration  time.Duration //expected duration, 0 skips the check
+	Tolerance time.Duration
+	Sample    int //probe at most Sample segments of every playlist, 0 probes all, -1 none
+	Fail      bool
+	Safe      bool
+}
+
+// ValidateOptions ...
+type ValidateOptions func(args *ValidateArgs)
+
+// ValidateDurationOption compare the total duration with d
+func ValidateDurationOption(d, tolerance time.Duration) ValidateOptions {
+	return func(args *ValidateArgs) {
+		args.Duration = d
+		args.Tolerance = tolerance
+	}
+}
+
+// ValidateSampleOption probe n evenly spaced segments of every playlist, 0 probes all and -1 none
+func ValidateSampleOption(n int) ValidateOptions {
+	return func(args *ValidateArgs) {
+		args.Sample = n
+	}
+}
+
+// ValidateFailOption fail the split when an issue is found
+func ValidateFailOption(b bool) ValidateOptions {
+	return func(args *ValidateArgs) {
+		args.Fail = b
+	}
+}
+
+func newValidateArgs(opts ...ValidateOptions) *ValidateArgs {
+	va := &ValidateArgs{Tolerance: time.Second}
+	for _, o := range opts {
+		o(va)
+	}
+	return va
+}
+
+// ValidateHLS check the package of the playlist in dir, a master playlist is checked with all its variants,
+// the error is only returned when the validation cannot run
+func ValidateHLS(dir string, playlist string, opts ...ValidateOptions) (*ValidationReport, error) {
+	return newValidateArgs(opts...).validate(dir, playlist)
+}
+
+func (va *ValidateArgs) validate(dir string, playlist string) (*ValidationReport, error) {
+	if _, e := os.Stat(dir); e != nil {
+		return nil, e
+	}
+	r := &ValidationReport{}
+	p, e := m3u8.ReadFile(filepath.Join(dir, playlist))
+	if e != nil {
+		r.add(IssuePlaylist, playlist, "", "%v", e)
+		return r, nil
+	}
+	r.Playlists = append(r.Playlists, playlist)
+	switch v := p.(type) {
+	case *m3u8.MasterPlaylist:
+		for _, m := range v.Media {
+			if m.URI != "" {
+				va.validateMedia(r, dir, m.URI, false)
+			}
+		}
+		for _, variant := range v.Variants {
+			va.validateMedia(r, dir, variant.URI, false)
+		}
+		for _, variant := range v.IFrames {
+			va.validateMedia(r, dir, variant.URI, true)
+		}
+	case *m3u8.MediaPlaylist:
+		va.checkMedia(r, dir, playlist, v, false)
+	}
+	return r, nil
+}
+
+func (va *ValidateArgs) validateMedia(r *ValidationReport, dir, uri string, iframe bool) {
+	r.Playlists = append(r.Playlists, uri)
+	f, e := os.Open(filepath.Join(dir, uri))
+	if e != nil {
+		r.add(IssuePlaylist, uri, "", "%v", e)
+		return
+	}
+	defer f.Close()
+	p, e := m3u8.ParseMedia(f)
+	if e != nil {
+		r.add(IssuePlaylist, uri, "", "%v", e)
+		return
+	}
+	va.checkMedia(r, dir, uri, p, iframe)
+}
+
+// checkMedia check the segments of a media playlist, segments of an I-frame playlist are not probed
+func (va *ValidateArgs) checkMedia(r *ValidationReport, dir, uri string, p *m3u8.MediaPlaylist, iframe bool) {
+	if !p.EndList {
+		r.add(IssueEndList, uri, "", "EXT-X-ENDLIST is missing")
+	}
+	base := filepath.Dir(filepath.Join(dir, uri))
+	probe := FFProbeStreamFormat
+	if va.Safe {
+		probe = FFProbeStreamFormatSafe
+	}
+	checked := map[string]bool{}
+	for i, s := range p.Segments {
+		if t := int(math.Round(s.Duration.Seconds())); t > p.TargetDuration {
+			r.add(IssueTargetDuration, uri, s.URI, "duration %.3fs exceeds the target %ds", s.Duration.Seconds(), p.TargetDuration)
+		}
+		if checked[s.URI] {
+			//byte ranges of the same file
+			continue
+		}
+		checked[s.URI] = true
+		r.Segments++
+		info, e := os.Stat(filepath.Join(base, s.URI))
+		if e != nil {
+			r.add(IssueMissingSegment, uri, s.URI, "%v", e)
+			continue
+		}
+		if info.Size() == 0 {
+			r.add(IssueEmptySegment, uri, s.URI, "the segment is empty")
+			continue
+		}
+		if iframe || !va.sampled(i, len(p.Segments)) {
+			continue
+		}
+		r.Probed++
+		sf, e := probe(filepath.Join(base, s.URI))
+		if e != nil {
+			r.add(IssueDecode, uri, s.URI, "%v", e)
+		} else if len(sf.Streams) == 0 {
+			r.add(IssueDecode, uri, s.URI, "no stream found")
+		}
+	}
+	if iframe {
+		return
+	}
+	total := p.Duration()
+	if r.Duration == 0 {
+		r.Duration = total
+	}
+	if va.Duration > 0 {
+		if diff := total - va.Duration; diff > va.Tolerance || -diff > va.Tolerance {
+			r.add(IssueDuration, uri, "", "duration %s differs from the source %s", total, va.Duration)
+		}
+	}
+}
+
+// sampled reports whether the segment i of n is probed
+func (va *ValidateArgs) sampled(i, n int) bool {
+	switch {
+	case va.Sample < 0:
+		return false
+	case va.Sample == 0 || va.Sample >= n:
+		return true
+	}
+	//the segments closest to Sample evenly spaced positions
+	step := float64(n) / float64(va.Sample)
+	k := int(float64(i) / step)
+	return i == int(float64(k)*step+step/2)
+}
+
+// ValidateOption validate the package after the split, the report is SplitArgs.Validation
+func ValidateOption(opts ...ValidateOptions) SplitOptions {
+	return func(args *SplitArgs) {
+		args.validate = newValidateArgs(opts...)
+	}
+}
+
+// validateOutput validate the split output in dir, the source duration is used when no duration is set
+func (sa *SplitArgs) validateOutput(dir string) error {
+	va := *sa.validate
+	va.Safe = sa.Safe
+	if va.Duration == 0 && sa.StreamFormat != nil {
+		if d, e := sa.StreamFormat.Format.DurationValue(); e == nil {
+			va.Duration = d
+		}
+	}
+	r, e := va.validate(dir, sa.M3U8)
+	if e != nil {
+		return e
+	}
+	sa.Validation = r
+	if !r.OK() {
+		log.With("issues", len(r.Issues)).Warn(r.Err())
+		if va.Fail {
+			return xerrors.Errorf("validate output: %w", r.Err())
+		}
+	}
+	return nil
+}
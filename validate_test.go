@@ -0,0 +1,91 @@
+package fftool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// TestValidateHLS ...
+func TestValidateHLS(t *testing.T) {
+	//every probe succeeds with one stream except the broken segment
+	defer fakeCommand(t, "ffprobe", `for last; do :; done
+case "$last" in
+*broken*) echo 'invalid data' >&2; exit 1 ;;
+esac
+echo '{"streams": [{"index": 0, "codec_type": "video"}], "format": {}}'
+`)()
+	dir, e := ioutil.TempDir("", "validate")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		if e := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); e != nil {
+			t.Fatal(e)
+		}
+	}
+	write("media.m3u8", "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nvideo_media.m3u8\n#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=100,URI=\"iframe_media.m3u8\"\n")
+	write("video_media.m3u8", "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.000,\na.ts\n#EXTINF:4.000,\nb.ts\n#EXTINF:2.000,\nc.ts\n#EXT-X-ENDLIST\n")
+	write("iframe_media.m3u8", "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-I-FRAMES-ONLY\n#EXTINF:4.000,\n#EXT-X-BYTERANGE:10@0\na.ts\n#EXTINF:4.000,\n#EXT-X-BYTERANGE:10@0\nb.ts\n#EXT-X-ENDLIST\n")
+	write("a.ts", "data")
+	write("b.ts", "data")
+	write("c.ts", "data")
+
+	r, e := ValidateHLS(dir, "media.m3u8", ValidateDurationOption(10*time.Second, time.Second))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !r.OK() || len(r.Playlists) != 3 || r.Segments != 5 || r.Probed != 3 || r.Duration != 10*time.Second {
+		t.Fatalf("%+v", r)
+	}
+
+	write("video_media.m3u8", "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:5.000,\na.ts\n#EXTINF:4.000,\nbroken.ts\n#EXTINF:2.000,\nempty.ts\n#EXTINF:2.000,\nmissing.ts\n")
+	write("broken.ts", "data")
+	write("empty.ts", "")
+	r, e = ValidateHLS(dir, "media.m3u8", ValidateDurationOption(10*time.Second, time.Second))
+	if e != nil {
+		t.Fatal(e)
+	}
+	kinds := map[IssueKind]int{}
+	for _, i := range r.Issues {
+		kinds[i.Kind]++
+	}
+	want := map[IssueKind]int{IssueEndList: 1, IssueTargetDuration: 1, IssueDecode: 1, IssueEmptySegment: 1, IssueMissingSegment: 1, IssueDuration: 1}
+	for k, n := range want {
+		if kinds[k] != n {
+			t.Errorf("%s: %d issue(s), want %d in %v", k, kinds[k], n, r.Issues)
+		}
+	}
+	var ve *ValidationError
+	if !xerrors.As(r.Err(), &ve) || ve.Report != r {
+		t.Fatal(r.Err())
+	}
+
+	r, e = ValidateHLS(dir, "missing.m3u8")
+	if e != nil || len(r.Issues) != 1 || r.Issues[0].Kind != IssuePlaylist {
+		t.Fatal(r, e)
+	}
+}
+
+// TestValidateArgs_Sampled ...
+func TestValidateArgs_Sampled(t *testing.T) {
+	va := &ValidateArgs{Sample: 3}
+	var probed []int
+	for i := 0; i < 10; i++ {
+		if va.sampled(i, 10) {
+			probed = append(probed, i)
+		}
+	}
+	if len(probed) != 3 || probed[0] != 1 || probed[1] != 5 || probed[2] != 8 {
+		t.Fatal(probed)
+	}
+	va.Sample = -1
+	if va.sampled(0, 1) {
+		t.Fatal("sampled with -1")
+	}
+}